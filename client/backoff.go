@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+const defaultRetryDelay = 100 * time.Millisecond
+
+// BackoffStrategy returns the delay to wait before the given retry attempt, starting at 0 for the first retry
+type BackoffStrategy func(attempt int) time.Duration
+
+// ExponentialBackoff returns a BackoffStrategy that doubles the delay with every attempt, starting at base and capped
+// at max, and applies full jitter (a random delay between 0 and the computed value)
+func ExponentialBackoff(base, max time.Duration) BackoffStrategy {
+	return func(attempt int) time.Duration {
+		if base <= 0 {
+			return 0
+		}
+		d := base
+		for i := 0; i < attempt; i++ {
+			if (max > 0 && d >= max) || d > math.MaxInt64/2 {
+				break
+			}
+			d *= 2
+		}
+		if max > 0 && d > max {
+			d = max
+		}
+		return rand.N(d + 1)
+	}
+}
+
+func (c *client) retryDelay(attempt int) time.Duration {
+	if c.backoff == nil {
+		return defaultRetryDelay
+	}
+	return c.backoff(attempt)
+}
+
+// sleepContext waits for the given duration, returning early with the context error if the context is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
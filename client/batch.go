@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"sync"
+)
+
+const defaultMaxConcurrency = 10
+
+type (
+	// BatchItem is a request of DoBatch and the value its response is decoded into, which may be nil
+	BatchItem struct {
+		Request  Request
+		Response interface{}
+	}
+
+	// BatchResult is the outcome of the BatchItem at the same index
+	BatchResult struct {
+		Response interface{}
+		Err      error
+	}
+)
+
+// DoBatch runs Do for every item, at most WithMaxConcurrency at a time. Results are in the order of the items
+func (c *client) DoBatch(ctx context.Context, items []BatchItem) []BatchResult {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	maxConcurrency := c.maxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = defaultMaxConcurrency
+	}
+
+	results := make([]BatchResult, len(items))
+	sem := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i] = BatchResult{Response: item.Response, Err: ctx.Err()}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = BatchResult{
+				Response: item.Response,
+				Err:      c.Do(ctx, item.Request, item.Response),
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// retryBudgetWindow is the period over which requests and retries are counted by the retry budget
+	retryBudgetWindow = 10 * time.Second
+	// retryBudgetBuckets is the number of one second buckets in the window
+	retryBudgetBuckets = int(retryBudgetWindow / time.Second)
+)
+
+type (
+	// retryBudget caps the retries of all requests of a client: within the window the retries may not exceed ratio
+	// times the requests plus minPerSec retries per second, so a failing service isn't flooded by retrying callers
+	retryBudget struct {
+		ratio     float64
+		minPerSec int
+
+		mu      sync.Mutex
+		buckets [retryBudgetBuckets]retryBudgetBucket
+	}
+
+	retryBudgetBucket struct {
+		second   int64
+		requests int
+		retries  int
+	}
+)
+
+func newRetryBudget(ratio float64, minPerSec int) *retryBudget {
+	return &retryBudget{
+		ratio:     ratio,
+		minPerSec: minPerSec,
+	}
+}
+
+// bucket returns the bucket of the second now falls in, resetting it when it was last used for an earlier second
+func (b *retryBudget) bucket(now time.Time) *retryBudgetBucket {
+	second := now.Unix()
+	index := int(second % int64(retryBudgetBuckets))
+	if index < 0 {
+		index += retryBudgetBuckets
+	}
+	bucket := &b.buckets[index]
+	if bucket.second != second {
+		*bucket = retryBudgetBucket{second: second}
+	}
+	return bucket
+}
+
+// deposit records a request
+func (b *retryBudget) deposit(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bucket(now).requests++
+}
+
+// withdraw records a retry, it returns false without recording it when the budget is exhausted
+func (b *retryBudget) withdraw(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.bucket(now)
+	var requests, retries int
+	for _, bucket := range b.buckets {
+		if now.Unix()-bucket.second < int64(retryBudgetBuckets) {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+
+	allowed := b.ratio*float64(requests) + float64(b.minPerSec*retryBudgetBuckets)
+	if float64(retries) >= allowed {
+		return false
+	}
+	current.retries++
+	return true
+}
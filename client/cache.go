@@ -0,0 +1,232 @@
+package client
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// CachedResponse is a successful GET response stored in a ResponseCache
+	CachedResponse struct {
+		StatusCode int
+		Header     http.Header
+		Body       []byte
+		ETag       string
+		Expires    time.Time
+		// Vary holds the request headers named by the Vary header of the response, the cached response is only used
+		// for requests with the same values
+		Vary http.Header
+	}
+
+	// ResponseCache stores responses keyed by method and url. It is not limited to a single client, so responses
+	// marked private, and responses to authorized requests unless marked public, are never stored
+	ResponseCache interface {
+		Get(key string) (*CachedResponse, bool)
+		Set(key string, resp *CachedResponse)
+	}
+
+	lruCache struct {
+		capacity int
+
+		mu      sync.Mutex
+		entries map[string]*list.Element
+		order   *list.List
+	}
+
+	lruEntry struct {
+		key  string
+		resp *CachedResponse
+	}
+)
+
+var _ ResponseCache = (*lruCache)(nil)
+
+// NewLRUCache returns an in-memory ResponseCache holding up to capacity responses, evicting the least recently used
+func NewLRUCache(capacity int) ResponseCache {
+	return &lruCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *lruCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry).resp, true
+}
+
+func (c *lruCache) Set(key string, resp *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*lruEntry).resp = resp
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, resp: resp})
+	for c.capacity > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (r *CachedResponse) isFresh(now time.Time) bool {
+	return now.Before(r.Expires)
+}
+
+// matches reports whether the request has the header values the cached response varies on
+func (r *CachedResponse) matches(req *http.Request) bool {
+	for name, values := range r.Vary {
+		if !slices.Equal(req.Header.Values(name), values) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *CachedResponse) toResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// lookupCache returns the cache key and cached response for cacheable requests, the key is empty when the request
+// can't be cached. Conditional requests of the caller aren't answered from cache, so they get their 304
+func (c *client) lookupCache(req *http.Request) (string, *CachedResponse) {
+	if c.cache == nil || req.Method != http.MethodGet {
+		return "", nil
+	}
+
+	key := req.Method + " " + req.URL.String()
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return key, nil
+	}
+	cached, ok := c.cache.Get(key)
+	if !ok || !cached.matches(req) {
+		return key, nil
+	}
+	return key, cached
+}
+
+// storeCache stores a successful response when its Cache-Control header allows it. Responses without a max-age are
+// only stored when they have an ETag, so they can be revalidated
+func (c *client) storeCache(key string, req *http.Request, resp *http.Response, authorized bool) {
+	now := c.getClock().Now()
+	expires, ok := cacheExpiry(resp.Header, now, authorized)
+	etag := resp.Header.Get("ETag")
+	if !ok || (!expires.After(now) && etag == "") {
+		return
+	}
+	vary, ok := varyHeaders(req, resp)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	c.cache.Set(key, &CachedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		ETag:       etag,
+		Expires:    expires,
+		Vary:       vary,
+	})
+}
+
+// revalidateCache handles a 304 response to a conditional request by refreshing the cached response and returning it
+func (c *client) revalidateCache(key string, cached *CachedResponse, notModified *http.Response, req *http.Request) *http.Response {
+	_ = notModified.Body.Close()
+
+	updated := *cached
+	if expires, ok := cacheExpiry(notModified.Header, c.getClock().Now(), false); ok {
+		updated.Expires = expires
+	}
+	c.cache.Set(key, &updated)
+	return updated.toResponse(req)
+}
+
+// cacheExpiry returns until when a response may be served from cache according to its Cache-Control header, the
+// second return value is false when the response may not be stored at all. Responses to authorized requests are only
+// stored when they are marked public
+func cacheExpiry(header http.Header, now time.Time, authorized bool) (time.Time, bool) {
+	var noCache, public bool
+	var maxAge time.Duration
+	for _, v := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			switch strings.ToLower(name) {
+			case "no-store", "private":
+				return time.Time{}, false
+			case "no-cache":
+				noCache = true
+			case "public":
+				public = true
+			case "max-age":
+				if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && seconds > 0 {
+					maxAge = time.Duration(seconds) * time.Second
+				}
+			}
+		}
+	}
+
+	if authorized && !public {
+		return time.Time{}, false
+	}
+	if noCache {
+		return now, true
+	}
+	return now.Add(maxAge), true
+}
+
+// varyHeaders returns the values of the request headers named by the Vary header of the response, the second return
+// value is false when the response varies on everything
+func varyHeaders(req *http.Request, resp *http.Response) (http.Header, bool) {
+	var vary http.Header
+	for _, v := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, false
+			}
+			if name == "" {
+				continue
+			}
+			if vary == nil {
+				vary = http.Header{}
+			}
+			vary[http.CanonicalHeaderKey(name)] = req.Header.Values(name)
+		}
+	}
+	return vary, true
+}
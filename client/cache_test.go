@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheSharedBetweenClientsWithDifferentCredentials(t *testing.T) {
+	tests := []struct {
+		name         string
+		cacheControl string
+		wantHits     int
+		wantBob      string
+	}{
+		{name: "not public", cacheControl: "max-age=60", wantHits: 2, wantBob: "Bearer bob"},
+		{name: "public", cacheControl: "public, max-age=60", wantHits: 1, wantBob: "Bearer alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits++
+				w.Header().Set("Cache-Control", tt.cacheControl)
+				writeJSON(w, http.StatusOK, fmt.Sprintf(`{"user":%q}`, r.Header.Get("Authorization")))
+			}))
+			t.Cleanup(srv.Close)
+
+			cache := NewLRUCache(10)
+			alice := NewClient(WithBaseURLString(srv.URL), WithCache(cache), WithBearerToken("alice"))
+			bob := NewClient(WithBaseURLString(srv.URL), WithCache(cache), WithBearerToken("bob"))
+
+			var resp struct {
+				User string `json:"user"`
+			}
+			req := testRequest{method: http.MethodGet, path: "/me"}
+			if err := alice.Do(context.Background(), req, &resp); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := bob.Do(context.Background(), req, &resp); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.User != tt.wantBob || hits != tt.wantHits {
+				t.Fatalf("expected %q after %d requests, got %q after %d", tt.wantBob, tt.wantHits, resp.User, hits)
+			}
+		})
+	}
+}
+
+func TestCacheHonorsVary(t *testing.T) {
+	hits := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		writeJSON(w, http.StatusOK, fmt.Sprintf(`{"language":%q}`, r.Header.Get("Accept-Language")))
+	}, WithCache(NewLRUCache(10)))
+
+	for _, tt := range []struct {
+		language string
+		wantHits int
+	}{
+		{language: "en", wantHits: 1},
+		{language: "nl", wantHits: 2},
+		{language: "nl", wantHits: 2},
+	} {
+		var resp struct {
+			Language string `json:"language"`
+		}
+		req := testRequest{method: http.MethodGet, path: "/", headers: http.Header{"Accept-Language": {tt.language}}}
+		if err := c.Do(context.Background(), req, &resp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Language != tt.language || hits != tt.wantHits {
+			t.Fatalf("expected %q after %d requests, got %q after %d", tt.language, tt.wantHits, resp.Language, hits)
+		}
+	}
+}
+
+func TestCacheHitSkipsAuthentication(t *testing.T) {
+	hits, authorized := 0, 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		writeJSON(w, http.StatusOK, `{}`)
+	}, WithCache(NewLRUCache(10)), WithPreflightAuth(func(req *http.Request, _ Client) (*http.Request, error) {
+		authorized++
+		req.Header.Set("Authorization", "Bearer token")
+		return req, nil
+	}))
+
+	for range 2 {
+		if err := c.Do(context.Background(), testRequest{method: http.MethodGet, path: "/"}, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if hits != 1 || authorized != 1 {
+		t.Fatalf("expected 1 request and 1 authentication, got %d and %d", hits, authorized)
+	}
+}
+
+// conditionalRequest is a GET request conditional on etag
+type conditionalRequest struct {
+	testRequest
+	etag string
+}
+
+func (r conditionalRequest) IfNoneMatch() string {
+	return r.etag
+}
+
+func (r conditionalRequest) IfModifiedSince() time.Time {
+	return time.Time{}
+}
+
+func TestCacheLeavesConditionalRequestsOfTheCaller(t *testing.T) {
+	var ifNoneMatch string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch = r.Header.Get("If-None-Match")
+		w.Header().Set("ETag", `"v1"`)
+		if ifNoneMatch != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		writeJSON(w, http.StatusOK, `{}`)
+	}, WithCache(NewLRUCache(10)))
+
+	// stores the response with an ETag, it has no max-age so it is revalidated on the next request
+	if err := c.Do(context.Background(), testRequest{method: http.MethodGet, path: "/"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := conditionalRequest{testRequest: testRequest{method: http.MethodGet, path: "/"}, etag: `"v0"`}
+	if err := c.Do(context.Background(), req, nil); !errors.Is(err, ErrNotModified) {
+		t.Fatalf("expected ErrNotModified, got %v", err)
+	}
+	if ifNoneMatch != `"v0"` {
+		t.Fatalf("expected the If-None-Match of the request, got %q", ifNoneMatch)
+	}
+}
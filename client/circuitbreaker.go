@@ -0,0 +1,136 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	circuitClosed = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+type (
+	// CircuitBreaker guards the http call of every attempt. Allow returns an error (usually ErrCircuitOpen) when no
+	// requests should be sent, Report receives the outcome of every request that was allowed. Requests cancelled by
+	// the caller aren't reported, they say nothing about the health of the service
+	CircuitBreaker interface {
+		Allow() error
+		Report(success bool)
+	}
+
+	// clockCircuitBreaker is implemented by the breaker of this package. The client calls it with the time of its
+	// clock instead of Allow and Report, and cancels allowed requests it doesn't report
+	clockCircuitBreaker interface {
+		allowAt(now time.Time) error
+		reportAt(success bool, now time.Time)
+		cancel()
+	}
+
+	countingCircuitBreaker struct {
+		failureThreshold int
+		resetTimeout     time.Duration
+
+		mu       sync.Mutex
+		state    int
+		failures int
+		openedAt time.Time
+		probing  bool
+	}
+)
+
+var (
+	_ CircuitBreaker      = (*countingCircuitBreaker)(nil)
+	_ clockCircuitBreaker = (*countingCircuitBreaker)(nil)
+)
+
+// NewCircuitBreaker returns a CircuitBreaker that opens after failureThreshold consecutive failures. After
+// resetTimeout a single probe request is allowed, which closes the circuit again when it succeeds
+func NewCircuitBreaker(failureThreshold int, resetTimeout time.Duration) CircuitBreaker {
+	return &countingCircuitBreaker{
+		failureThreshold: failureThreshold,
+		resetTimeout:     resetTimeout,
+	}
+}
+
+func (cb *countingCircuitBreaker) Allow() error {
+	return cb.allowAt(time.Now())
+}
+
+func (cb *countingCircuitBreaker) Report(success bool) {
+	cb.reportAt(success, time.Now())
+}
+
+func (cb *countingCircuitBreaker) allowAt(now time.Time) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if now.Sub(cb.openedAt) < cb.resetTimeout {
+			return ErrCircuitOpen
+		}
+		cb.state = circuitHalfOpen
+		cb.probing = true
+		return nil
+	case circuitHalfOpen:
+		if cb.probing {
+			return ErrCircuitOpen
+		}
+		cb.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (cb *countingCircuitBreaker) reportAt(success bool, now time.Time) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if success {
+		cb.state = circuitClosed
+		cb.failures = 0
+		cb.probing = false
+		return
+	}
+
+	cb.failures++
+	if cb.state == circuitHalfOpen || cb.failures >= cb.failureThreshold {
+		cb.state = circuitOpen
+		cb.openedAt = now
+		cb.probing = false
+	}
+}
+
+// cancel frees the probe of a half open circuit, so the next request can probe instead
+func (cb *countingCircuitBreaker) cancel() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == circuitHalfOpen {
+		cb.probing = false
+	}
+}
+
+func (c *client) allowCircuit() error {
+	if cb, ok := c.circuitBreaker.(clockCircuitBreaker); ok {
+		return cb.allowAt(c.getClock().Now())
+	}
+	return c.circuitBreaker.Allow()
+}
+
+func (c *client) reportCircuit(success bool) {
+	if cb, ok := c.circuitBreaker.(clockCircuitBreaker); ok {
+		cb.reportAt(success, c.getClock().Now())
+		return
+	}
+	c.circuitBreaker.Report(success)
+}
+
+func (c *client) cancelCircuit() {
+	if cb, ok := c.circuitBreaker.(clockCircuitBreaker); ok {
+		cb.cancel()
+	}
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testClock is a Clock that only moves when advanced or slept on
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.advance(d)
+	return ctx.Err()
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestCircuitBreakerUsesTheClientClock(t *testing.T) {
+	hits := 0
+	clock := &testClock{now: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		writeJSON(w, http.StatusInternalServerError, `{}`)
+	}, WithCircuitBreaker(NewCircuitBreaker(1, time.Minute)), WithClock(clock))
+
+	ctx := context.Background()
+	req := testRequest{method: http.MethodGet, path: "/"}
+	if err := c.Do(ctx, req, nil); errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the first request to be sent, got %v", err)
+	}
+	if err := c.Do(ctx, req, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	clock.advance(time.Minute)
+	if err := c.Do(ctx, req, nil); errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected a probe after the reset timeout, got %v", err)
+	}
+	if hits != 2 {
+		t.Fatalf("expected 2 requests, got %d", hits)
+	}
+}
+
+func TestCallerCancellationDoesNotTripTheCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name     string
+		halfOpen bool
+	}{
+		{name: "closed"},
+		{name: "half open", halfOpen: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			clock := &testClock{now: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/fail":
+					writeJSON(w, http.StatusInternalServerError, `{}`)
+				case "/hang":
+					cancel()
+					<-r.Context().Done()
+				default:
+					writeJSON(w, http.StatusOK, `{}`)
+				}
+			}, WithCircuitBreaker(NewCircuitBreaker(1, time.Minute)), WithClock(clock))
+
+			if tt.halfOpen {
+				_ = c.Do(context.Background(), testRequest{method: http.MethodGet, path: "/fail"}, nil)
+				clock.advance(time.Minute)
+			}
+
+			if err := c.Do(ctx, testRequest{method: http.MethodGet, path: "/hang"}, nil); !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+			if err := c.Do(context.Background(), testRequest{method: http.MethodGet, path: "/"}, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestPerAttemptTimeoutTripsTheCircuitBreaker(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}, WithCircuitBreaker(NewCircuitBreaker(1, time.Minute)), WithPerAttemptTimeout(50*time.Millisecond))
+
+	if err := c.Do(context.Background(), testRequest{method: http.MethodGet, path: "/"}, nil); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	if err := c.Do(context.Background(), testRequest{method: http.MethodGet, path: "/"}, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+}
@@ -3,31 +3,44 @@ package client
 import (
 	"bytes"
 	"context"
+	"encoding"
 	"errors"
 	"fmt"
 	jsoniter "github.com/json-iterator/go"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"net/http/cookiejar"
 	"net/http/httputil"
 	"net/url"
-	"path"
 	"reflect"
 	"slices"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 )
 
 const (
 	mediaType      = "application/json"
+	formMediaType  = "application/x-www-form-urlencoded"
 	libraryVersion = "0.0.1"
 	userAgent      = "omniboost/" + libraryVersion
 	defaultCharset = "utf-8"
+	// defaultName is the tracer and meter name of clients without WithName
+	defaultName = "github.com/omniboost/go-omniboost-http-client/client"
+)
+
+// media types for RequestWithPatchType
+const (
+	MergePatchMediaType = "application/merge-patch+json"
+	JSONPatchMediaType  = "application/json-patch+json"
 )
 
 const (
@@ -36,6 +49,10 @@ const (
 	authTypeApiKey
 	authTypeOAuth2
 	authTypePreflight
+	authTypeBearer
+	authTypeHMAC
+	authTypeDigest
+	authTypeAWSSigV4
 )
 
 type (
@@ -44,28 +61,93 @@ type (
 		baseClient            *http.Client
 		parentClient          Client
 		debug                 bool
+		logger                *slog.Logger
+		redactedHeaders       []string
+		acceptEncodings       []string
+		maxResponseBytes      int64
+		maxErrorBodyBytes     int64
+		requestCompression    bool
+		compressionMinSize    int
 		userAgent             string
 		mediaType             string
 		charset               string
+		preserveTemplateQuery bool
+		queryEncoder          func(url.Values) string
+		basePath              string
 		baseURL               *url.URL
 		disallowUnknownFields bool
+		idempotencyKeyHeader  string
+		methodOverrideHeader  string
+		acceptVendor          string
+		acceptVersion         string
+		insecureSkipVerify    bool
+		insecureWarning       sync.Once
+		optionErrs            []error
+		allowBodyOnGet        bool
+		ownHttpClient         bool
 		useCookies            bool
+		cookieOption          bool
+		cookieJar             http.CookieJar
+		cookieLock            sync.Mutex
+		cookieClient          *http.Client
+		cookieClientBase      *http.Client
+		defaultHeaders        http.Header
+		contextHeaders        []contextHeader
 
 		authType          int
 		userName          string
 		password          string
+		basicAuthHeader   string
 		keyHeader         string
 		keyValue          string
+		bearerToken       string
+		sigV4             *awsSigV4
+		hmacConfig        HMACConfig
+		digestAuth        *digestAuth
 		maxRetries        int
+		perAttemptTimeout time.Duration
+		maxConcurrency    int
+		timeout           time.Duration
+		backoff           BackoffStrategy
+		maxRetryAfter     time.Duration
+		retryableStatus   []int
+		retryPredicate    func(resp *http.Response, err error) bool
+		retryBudget       *retryBudget
+		bodyTee           func(ctx context.Context, reqBody, respBody []byte)
+		rateLimiter       RateLimiter
+		circuitBreaker    CircuitBreaker
+		cache             ResponseCache
 		tokenSource       oauth2.TokenSource
+		oauth2Config      *clientcredentials.Config
+		tokens            *tokenCache
 		jsoniterInstance  jsoniter.API
+		dynamicJsoniter   jsoniter.API
+		responseUnwrapper func(raw []byte) ([]byte, error)
+		jsoniterLock      sync.Mutex
+		jsoniterConfig    *jsoniter.Config
+		codec             Codec
+		middleware        []Middleware
+		requestHooks      []func(*http.Request)
+		responseHooks     []func(*http.Response)
+		clock             Clock
+		name              string
+		semconvKeys       *semconvKeys
+		meterProvider     metric.MeterProvider
+		propagator        propagation.TextMapPropagator
+		tracerProvider    trace.TracerProvider
+		metrics           *clientMetrics
 		preflightAuthFunc func(req *http.Request, client Client) (*http.Request, error)
 	}
 
 	Client interface {
 		ApplyOption(options Option)
 		Do(ctx context.Context, request Request, response interface{}) error
+		DoRaw(ctx context.Context, request Request) ([]byte, *http.Response, error)
+		DoJSON(ctx context.Context, request Request) (any, error)
+		Subscribe(ctx context.Context, request Request, events chan<- SSEEvent) error
+		DoBatch(ctx context.Context, items []BatchItem) []BatchResult
 		GetJsoniter() jsoniter.API
+		Marshal(v any) ([]byte, error)
 		GetParentClient() Client
 
 		private() // just here to make sure only our package can implement this interface
@@ -83,23 +165,127 @@ type (
 		ErrorStructs() []error
 	}
 
+	// contextHeader is a header set from a value of the request context
+	contextHeader struct {
+		contextKey any
+		header     string
+	}
+
+	// RequestWithBody is sent with its body for every method, including DELETE, except GET and HEAD unless
+	// WithAllowBodyOnGet is used. Reader bodies are only retried when they implement io.Seeker and not io.Closer
 	RequestWithBody interface {
 		Request
 		Body() any
 	}
 
+	// RequestWithContentLength provides the length of an io.Reader body, so it is sent with a Content-Length header
+	// instead of chunked. A negative length means unknown. It is ignored for other bodies
+	RequestWithContentLength interface {
+		Request
+		ContentLength() int64
+	}
+
+	// RequestWithHeaders adds headers to the request, overriding the client defaults (Content-Type, Accept, User-Agent)
+	// for the same keys. Auth headers are applied after these, so they take precedence over request headers
+	RequestWithHeaders interface {
+		Request
+		Headers() http.Header
+	}
+
+	// RequestWithStatusReceiver receives the status code of the response, including error responses. When the request
+	// is retried it is called for every attempt, so the last call holds the final status code
+	RequestWithStatusReceiver interface {
+		Request
+		SetStatusCode(statusCode int)
+	}
+
+	// RequestWithResponseHeaders receives the headers of a successful response, also when no response is decoded
+	RequestWithResponseHeaders interface {
+		Request
+		SetResponseHeaders(header http.Header)
+	}
+
+	// RequestWithStreamHandler receives the body of a successful response as it is read from the connection, instead of
+	// it being buffered and decoded. The handler should read until EOF, the body is closed once it returns
+	RequestWithStreamHandler interface {
+		Request
+		HandleStream(r io.Reader) error
+	}
+
 	RequestWithAuthPreference interface {
 		Request
 		SkipAuth() bool
 	}
 
+	RequestWithMediaType interface {
+		Request
+		MediaType() string
+	}
+
+	// RequestWithPatchType sets the Content-Type of a patch body, like MergePatchMediaType, the Accept header keeps
+	// the media type of the client or request
+	RequestWithPatchType interface {
+		Request
+		PatchType() string
+	}
+
+	RequestWithCharset interface {
+		Request
+		Charset() string
+	}
+
+	RequestWithValidation interface {
+		Request
+		Validate() error
+	}
+
+	// ResponseValidator is implemented by response values that check their own invariants after decoding
+	ResponseValidator interface {
+		Validate() error
+	}
+
+	RequestWithIdempotency interface {
+		Request
+		UseIdempotencyKey() bool
+	}
+
+	// RequestWithIdempotent overrides whether a request may be retried, which by default depends on its method
+	RequestWithIdempotent interface {
+		Request
+		Idempotent() bool
+	}
+
+	// RequestWithConditional sends If-None-Match and If-Modified-Since headers for the non-empty values, a 304 response
+	// is returned as ErrNotModified
+	RequestWithConditional interface {
+		Request
+		IfNoneMatch() string
+		IfModifiedSince() time.Time
+	}
+
+	RequestWithAttemptObserver interface {
+		Request
+		SetAttempts(attempts int)
+	}
+
 	ContextKey string
+
+	// contextKey is unexported so it can't collide with keys set by other packages
+	contextKey int
 )
 
 const (
-	contextKeyAttempt = ContextKey("attempt")
+	contextKeyAttempt contextKey = iota
+	contextKeyIdempotencyKey
+	contextKeyBodyTee
 )
 
+// AttemptFromContext returns the zero based attempt number of the request in progress
+func AttemptFromContext(ctx context.Context) (int, bool) {
+	attempt, ok := ctx.Value(contextKeyAttempt).(int)
+	return attempt, ok
+}
+
 func (c *client) ApplyOption(options Option) {
 	options(c)
 }
@@ -111,10 +297,14 @@ var _ Client = (*client)(nil)
 
 func NewClient(opts ...Option) Client {
 	c := &client{
-		userAgent:  userAgent,
-		mediaType:  mediaType,
-		httpClient: http.DefaultClient,
-		charset:    defaultCharset,
+		userAgent:          userAgent,
+		mediaType:          mediaType,
+		httpClient:         newDefaultHttpClient(),
+		charset:            defaultCharset,
+		compressionMinSize: defaultCompressionMinSize,
+		maxErrorBodyBytes:  defaultMaxErrorBodyBytes,
+		maxRetryAfter:      defaultMaxRetryAfter,
+		tokens:             newTokenCache(),
 	}
 	for _, opt := range opts {
 		opt(c)
@@ -127,97 +317,473 @@ func (c *client) GetParentClient() Client {
 }
 
 func (c *client) Do(ctx context.Context, request Request, response interface{}) error {
-	if c.baseURL == nil {
+	if len(c.optionErrs) > 0 {
+		return fmt.Errorf("invalid client configuration: %w", errors.Join(c.optionErrs...))
+	}
+	if c.getBaseURL() == nil {
 		return errors.New("client base URL not set")
 	}
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	span := trace.SpanFromContext(ctx)
-	if span.IsRecording() {
-		_, span = span.TracerProvider().Tracer("kahn").Start(
-			ctx,
-			"http request",
-		)
-		defer span.End()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	if c.insecureSkipVerify {
+		c.warnInsecure(ctx)
+	}
+	ctx, span := c.tracer().Start(
+		ctx,
+		"http request",
+		trace.WithSpanKind(trace.SpanKindClient),
+	)
+	defer span.End()
+
+	if reqWithValidation, ok := request.(RequestWithValidation); ok {
+		if err := reqWithValidation.Validate(); err != nil {
+			span.RecordError(err, trace.WithStackTrace(true))
+			return fmt.Errorf("invalid request: %w", err)
+		}
+	}
+
+	idempotencyKey, err := c.idempotencyKey(request)
+	if err != nil {
+		span.RecordError(err, trace.WithStackTrace(true))
+		return err
+	}
+	if idempotencyKey != "" {
+		ctx = context.WithValue(ctx, contextKeyIdempotencyKey, idempotencyKey)
+	}
+
+	if c.retryBudget != nil {
+		c.retryBudget.deposit(c.getClock().Now())
 	}
 
-	if c.useCookies {
-		if c.httpClient.Jar == nil {
-			c.httpClient.Jar, _ = cookiejar.New(nil)
+	if c.bodyTee != nil {
+		tee := &bodyTee{}
+		ctx = context.WithValue(ctx, contextKeyBodyTee, tee)
+		defer func() {
+			c.bodyTee(ctx, tee.request, tee.response)
+		}()
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, delay, err := c.attempt(ctx, span, attempt, request, response)
+
+		attrs := []attribute.KeyValue{attribute.Int("http.attempt", attempt)}
+		if err != nil {
+			attrs = append(attrs, attribute.String("error", err.Error()))
 		}
-	} else {
-		c.httpClient.Jar = nil
+		span.AddEvent("http attempt", trace.WithAttributes(attrs...))
+
+		if attempt > 0 {
+			span.SetAttributes(c.semconv().resendCount.Int(attempt))
+		}
+
+		// credentials are fetched before the request is sent, failing to get them can't have had side effects
+		if retry && !errors.Is(err, ErrAuthFailure) && !c.canRetry(request, idempotencyKey) {
+			retry = false
+		}
+		if retry && attempt < c.maxRetries && !c.canRewindBody(request) {
+			retry = false
+			c.log(ctx, slog.LevelWarn, "not retrying http request",
+				fmt.Sprintf("Attempt %d failed, not retrying as the request body can't be rewound: %s", attempt, err.Error()),
+				slog.Int("attempt", attempt),
+				slog.String("error", err.Error()),
+			)
+		}
+
+		exhausted := retry && attempt < c.maxRetries && c.retryBudget != nil && !c.retryBudget.withdraw(c.getClock().Now())
+		if exhausted {
+			span.AddEvent("retry budget exhausted")
+			c.log(ctx, slog.LevelWarn, "retry budget exhausted",
+				fmt.Sprintf("Attempt %d failed, not retrying as the retry budget is exhausted: %s", attempt, err.Error()),
+				slog.Int("attempt", attempt),
+				slog.String("error", err.Error()),
+			)
+		}
+
+		if !retry || attempt >= c.maxRetries || exhausted {
+			if r, ok := request.(RequestWithAttemptObserver); ok {
+				r.SetAttempts(attempt + 1)
+			}
+			c.setErrorType(span, err)
+			return err
+		}
+
+		c.log(ctx, slog.LevelWarn, "retrying http request",
+			fmt.Sprintf("Attempt %d failed, retrying in %s: %s", attempt, delay, err.Error()),
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+			slog.String("error", err.Error()),
+		)
+		if c.metrics != nil {
+			c.metrics.retry(ctx, request.Method())
+		}
+		if sleepErr := c.getClock().Sleep(ctx, delay); sleepErr != nil {
+			if r, ok := request.(RequestWithAttemptObserver); ok {
+				r.SetAttempts(attempt + 1)
+			}
+			err = fmt.Errorf("failed to do http request: %w", errors.Join(err, wrapTimeout(sleepErr)))
+			c.setErrorType(span, err)
+			return err
+		}
+	}
+}
+
+// rawResponse is passed as response by DoRaw to receive the body as is, instead of decoding it
+type rawResponse struct {
+	body     []byte
+	response *http.Response
+}
+
+// DoRaw does the request like Do, but returns the raw response body and the response instead of decoding it. Error
+// responses still result in an ErrorResponse
+func (c *client) DoRaw(ctx context.Context, request Request) ([]byte, *http.Response, error) {
+	raw := &rawResponse{}
+	if err := c.Do(ctx, request, raw); err != nil {
+		return nil, nil, err
 	}
+	return raw.body, raw.response, nil
+}
 
-	// todo: add ratelimiting etc
+// dynamicResponse is passed as response by DoJSON to decode the body into a generic value
+type dynamicResponse struct {
+	value any
+}
 
-	req, err := getHttpRequest(ctx, request, *c.baseURL)
+// DoJSON does the request like Do, but decodes the JSON response body into a generic value (maps, slices, strings,
+// float64s, bools or nil) for payloads without a matching struct. Unknown fields are never rejected, error responses
+// are handled like in Do
+func (c *client) DoJSON(ctx context.Context, request Request) (any, error) {
+	dynamic := &dynamicResponse{}
+	if err := c.Do(ctx, request, dynamic); err != nil {
+		return nil, err
+	}
+	return dynamic.value, nil
+}
+
+// unmarshalDynamic decodes r into v with the client's jsoniter config, ignoring WithDisallowUnknownFields. An empty
+// body leaves v nil
+func (c *client) unmarshalDynamic(r io.Reader, v *any) error {
+	b, err := io.ReadAll(r)
 	if err != nil {
-		span.RecordError(err, trace.WithStackTrace(true))
 		return err
 	}
-	span.SetAttributes(
-		attribute.String("http.method", req.Method),
-		attribute.String("http.url", req.URL.String()),
-	)
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+
+	return c.getDynamicJsoniter().Unmarshal(b, v)
+}
+
+// attempt runs a single attempt, limited by the per attempt timeout. An attempt timing out is retried while the
+// context of Do isn't done
+func (c *client) attempt(ctx context.Context, span trace.Span, attempt int, request Request, response interface{}) (bool, time.Duration, error) {
+	attemptCtx := context.WithValue(ctx, contextKeyAttempt, attempt)
+	if c.perAttemptTimeout <= 0 {
+		return c.doAttempt(attemptCtx, span, attempt, request, response)
+	}
+
+	attemptCtx, cancel := context.WithTimeoutCause(attemptCtx, c.perAttemptTimeout, errAttemptTimeout)
+	defer cancel()
+
+	retry, delay, err := c.doAttempt(attemptCtx, span, attempt, request, response)
+	if err != nil && !retry && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		// running out of time is retried like a failed request, unless the retry predicate rejects it
+		if c.retryPredicate != nil {
+			return c.retryPredicate(nil, err), c.retryDelay(attempt), err
+		}
+		return true, c.retryDelay(attempt), err
+	}
+	return retry, delay, err
+}
+
+// doAttempt performs a single attempt of the request. When the attempt failed in a way that can be retried, retry is
+// true and delay holds the time to wait before the next attempt
+func (c *client) doAttempt(ctx context.Context, span trace.Span, attempt int, request Request, response interface{}) (retry bool, delay time.Duration, err error) {
+	req, err := c.getHttpRequest(ctx, request)
+	if err != nil {
+		span.RecordError(err, trace.WithStackTrace(true))
+		return false, 0, err
+	}
+	span.SetAttributes(c.requestAttributes(req)...)
+
+	tee := bodyTeeFromContext(ctx)
+	if tee != nil {
+		if err := tee.teeRequestBody(req); err != nil {
+			span.RecordError(err, trace.WithStackTrace(true))
+			return false, 0, err
+		}
+	}
+
+	mediaType, charset := c.mediaType, c.charset
+	if reqWithMediaType, ok := request.(RequestWithMediaType); ok && reqWithMediaType.MediaType() != "" {
+		mediaType = reqWithMediaType.MediaType()
+	}
+	if reqWithCharset, ok := request.(RequestWithCharset); ok && reqWithCharset.Charset() != "" {
+		charset = reqWithCharset.Charset()
+	}
+
+	contentType := mediaType
+	if reqWithPatchType, ok := request.(RequestWithPatchType); ok && reqWithPatchType.PatchType() != "" {
+		contentType = reqWithPatchType.PatchType()
+	}
+
+	// set other headers
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	if hasBody && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", c.acceptMediaType(mediaType))
+	}
+	if len(c.acceptEncodings) > 0 && req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", strings.Join(c.acceptEncodings, ", "))
+	}
+	if req.Header.Get("User-Agent") == "" {
+		// an empty value stops net/http from sending its own default
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	for k, vv := range c.defaultHeaders {
+		if isReservedHeader(k) {
+			req.Header.Del(k)
+		}
+		for _, v := range vv {
+			req.Header.Add(k, v)
+		}
+	}
+
+	for _, h := range c.contextHeaders {
+		if v, ok := ctx.Value(h.contextKey).(string); ok && v != "" {
+			req.Header.Set(h.header, v)
+		}
+	}
+
+	// request headers, from header tags and RequestWithHeaders, override the defaults above, auth is applied afterwards
+	// so it can't be clobbered by them
+	for _, f := range getTaggedFieldList(request, "header") {
+		req.Header.Set(f.name, formatHeaderValue(f.value, f.options))
+	}
+	if reqWithHeaders, ok := request.(RequestWithHeaders); ok {
+		for k, vv := range reqWithHeaders.Headers() {
+			req.Header.Del(k)
+			for _, v := range vv {
+				req.Header.Add(k, v)
+			}
+		}
+	}
+
+	if key, ok := ctx.Value(contextKeyIdempotencyKey).(string); ok && req.Header.Get(c.getIdempotencyKeyHeader()) == "" {
+		req.Header.Set(c.getIdempotencyKeyHeader(), key)
+	}
+
+	if stream, ok := response.(*sseStream); ok {
+		stream.prepare(req)
+	}
+
+	if reqWithConditional, ok := request.(RequestWithConditional); ok {
+		if etag := reqWithConditional.IfNoneMatch(); etag != "" && req.Header.Get("If-None-Match") == "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if since := reqWithConditional.IfModifiedSince(); !since.IsZero() && req.Header.Get("If-Modified-Since") == "" {
+			req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+		}
+	}
+
+	// a fresh cached response is served before authenticating, so no token is fetched and nothing is signed for it
+	cacheKey, cached := c.lookupCache(req)
+	fromCache := cached != nil && cached.isFresh(c.getClock().Now())
 
 	skipAuth := false
 	if reqWithAuthPreference, ok := request.(RequestWithAuthPreference); ok {
 		skipAuth = reqWithAuthPreference.SkipAuth()
 	}
 
-	if !skipAuth {
-		switch c.authType {
+	// a client without authentication of its own uses the authentication of its parent
+	auth := c.authClient()
+	if !skipAuth && !fromCache {
+		switch auth.authType {
 		case authTypeBasic:
-			req.SetBasicAuth(c.userName, c.password)
+			req.Header.Set("Authorization", auth.basicAuthHeader)
 		case authTypeApiKey:
-			req.Header.Add(c.keyHeader, c.keyValue)
+			req.Header.Set(auth.keyHeader, auth.keyValue)
+		case authTypeOAuth2:
+			token, err := auth.oauth2Token(ctx)
+			if err != nil {
+				span.RecordError(err, trace.WithStackTrace(true))
+				return isRetryableTokenError(err), c.retryDelay(attempt), fmt.Errorf("failed to get oauth2 token: %w: %w", ErrAuthFailure, err)
+			}
+			token.SetAuthHeader(req)
+		case authTypeBearer:
+			req.Header.Set("Authorization", "Bearer "+auth.bearerToken)
+		case authTypeHMAC:
+			if err := auth.signHMAC(req); err != nil {
+				span.RecordError(err, trace.WithStackTrace(true))
+				return false, 0, err
+			}
+		case authTypeDigest:
+			if err := auth.digestAuth.authorize(req); err != nil {
+				span.RecordError(err, trace.WithStackTrace(true))
+				return false, 0, err
+			}
+		case authTypeAWSSigV4:
+			if err := auth.signSigV4(req); err != nil {
+				span.RecordError(err, trace.WithStackTrace(true))
+				return false, 0, err
+			}
 		case authTypePreflight:
-			if c.preflightAuthFunc != nil {
-				req, err = c.preflightAuthFunc(req, c)
+			if auth.preflightAuthFunc != nil {
+				// the body is buffered so the preflight function can read it, to sign it for example, and is reset
+				// afterwards so it is still sent
+				if _, err := readRequestBody(req); err != nil {
+					span.RecordError(err, trace.WithStackTrace(true))
+					return false, 0, err
+				}
+				req, err = auth.preflightAuthFunc(req, auth)
 				if err != nil {
 					span.RecordError(err, trace.WithStackTrace(true))
-					return err
+					return false, 0, err
+				}
+				if err := resetRequestBody(req); err != nil {
+					span.RecordError(err, trace.WithStackTrace(true))
+					return false, 0, err
 				}
 			}
 		default:
 		}
 	}
 
-	// set other headers
-	req.Header.Add("Content-Type", fmt.Sprintf("%s; charset=%s", c.mediaType, c.charset))
-	req.Header.Add("Accept", c.mediaType)
-	req.Header.Add("User-Agent", c.userAgent)
-
-	if c.debug {
-		dump, _ := httputil.DumpRequestOut(req, true)
-		log.Println(string(dump))
+	var digest *digestAuth
+	if !skipAuth && auth.authType == authTypeDigest {
+		digest = auth.digestAuth
 	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		span.RecordError(err, trace.WithStackTrace(true))
+	var resp *http.Response
+	var sent time.Time
+	var timeToFirstByte time.Duration
+	if fromCache {
+		resp = cached.toResponse(req)
+	} else {
+		if cached != nil && cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+
+		sent = time.Now()
+		resp, retry, err = c.send(ctx, span, attempt, req, digest)
+		if err != nil {
+			if errors.Is(context.Cause(ctx), errAttemptTimeout) {
+				// attempt decides on retrying a per-attempt timeout
+				retry = false
+			} else if c.retryPredicate != nil {
+				retry = c.retryPredicate(nil, err)
+			}
+			return retry, c.retryDelay(attempt), err
+		}
+		timeToFirstByte = time.Since(sent)
 
-		if c.debug {
-			log.Printf("Request failed: %s", err.Error())
+		if cached != nil && resp.StatusCode == http.StatusNotModified {
+			resp = c.revalidateCache(cacheKey, cached, resp, req)
+			fromCache = true
 		}
-		attempt, _ := ctx.Value(contextKeyAttempt).(int)
-		if attempt < c.maxRetries {
-			time.Sleep(100 * time.Millisecond)
-			ctx = context.WithValue(ctx, contextKeyAttempt, attempt+1)
-			span.End()
-			return c.Do(ctx, request, response)
+	}
+	// the received body is drained and closed on every return, the buffered bodies replacing it along the way are
+	// left readable for DoRaw and ErrorResponse
+	defer drainAndClose(resp.Body)
+
+	span.SetAttributes(c.semconv().statusCode.Int(resp.StatusCode))
+	if reqWithStatus, ok := request.(RequestWithStatusReceiver); ok {
+		reqWithStatus.SetStatusCode(resp.StatusCode)
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		if reqWithHeaders, ok := request.(RequestWithResponseHeaders); ok {
+			reqWithHeaders.SetResponseHeaders(resp.Header)
 		}
+		return false, 0, NewErrorResponse(resp.Status, resp, ErrNotModified)
+	}
 
-		return fmt.Errorf("failed to do http request: %w", err)
+	if err := decompressResponse(resp); err != nil {
+		span.RecordError(err, trace.WithStackTrace(true))
+		return false, 0, NewErrorResponse("failed to read response body", resp, err)
 	}
-	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+
+	if c.maxResponseBytes > 0 {
+		resp.Body = newLimitedBody(resp.Body, c.maxResponseBytes)
+	}
+
+	if handler := getStreamHandler(request, response); handler != nil && checkForErrorResponse(resp) == nil {
+		return false, 0, c.handleStream(ctx, span, attempt, req, resp, request, handler)
+	}
+
+	counter := &countingBody{ReadCloser: resp.Body}
+	resp.Body = counter
 
 	// we always run the dump response so we have a no-op io.Reader to read the body
-	dump, _ := httputil.DumpResponse(resp, true)
-	if c.debug {
-		log.Println(string(dump))
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		_ = resp.Body.Close()
+		span.RecordError(err, trace.WithStackTrace(true))
+		return false, 0, NewErrorResponse("failed to read response body", resp, err)
+	}
+	if tee != nil {
+		if err := tee.teeResponseBody(resp); err != nil {
+			span.RecordError(err, trace.WithStackTrace(true))
+			return false, 0, NewErrorResponse("failed to read response body", resp, err)
+		}
+	}
+
+	span.SetAttributes(c.semconv().contentLength.Int64(counter.n))
+	responseAttrs := []attribute.KeyValue{
+		attribute.Int("http.attempt", attempt),
+		c.semconv().contentLength.Int64(counter.n),
+		attribute.Bool("http.cached", fromCache),
+	}
+	if !sent.IsZero() {
+		responseAttrs = append(responseAttrs,
+			attribute.Float64("http.time_to_first_byte_ms", float64(timeToFirstByte.Microseconds())/1000),
+			attribute.Float64("http.duration_ms", float64(time.Since(sent).Microseconds())/1000),
+		)
+	}
+	span.AddEvent("http response", trace.WithAttributes(responseAttrs...))
+	if c.debugEnabled(ctx) {
+		c.log(ctx, slog.LevelDebug, "http response", string(dump),
+			slog.String("http.method", req.Method),
+			slog.String("http.url", req.URL.String()),
+			slog.Int("attempt", attempt),
+			slog.Int("status_code", resp.StatusCode),
+			slog.String("dump", string(dump)),
+		)
+	}
+
+	if attempt < c.maxRetries {
+		retry, err := c.retryResponse(resp)
+		if err != nil {
+			span.RecordError(err, trace.WithStackTrace(true))
+			return false, 0, NewErrorResponse("failed to read response body", resp, err)
+		}
+		if retry {
+			errResponse := checkForErrorResponse(resp)
+			if errResponse == nil {
+				e := NewErrorResponse(fmt.Sprintf("retry requested for %s", resp.Status), resp, nil)
+				errResponse = &e
+			}
+			if err := c.captureErrorBody(resp, errResponse); err != nil {
+				span.RecordError(err, trace.WithStackTrace(true))
+				return false, 0, NewErrorResponse("failed to read response body", resp, err)
+			}
+			delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), c.getClock().Now())
+			if !ok {
+				delay = c.retryDelay(attempt)
+			} else if delay > c.maxRetryAfter {
+				// the server asks to come back later than we're willing to wait
+				return false, 0, *errResponse
+			}
+			return true, delay, *errResponse
+		}
 	}
 
 	errorStructs := make([]error, 0)
@@ -225,10 +791,23 @@ func (c *client) Do(ctx context.Context, request Request, response interface{})
 		errorStructs = reqWithErrors.ErrorStructs()
 	}
 
-	// todo: untested, since our test api has no response bodies
 	if errResponse := checkForErrorResponse(resp); errResponse != nil {
-		if err := c.Unmarshal(resp.Body, errorStructs); err != nil {
-			return *errResponse
+		if err := c.captureErrorBody(resp, errResponse); err != nil {
+			span.RecordError(err, trace.WithStackTrace(true))
+			return false, 0, NewErrorResponse("failed to read response body", resp, err)
+		}
+
+		if !isDecodable(resp, mediaType) {
+			return false, 0, c.unexpectedContentType(resp.Status, resp)
+		}
+
+		// every error struct is decoded individually, they have to be pointers to be populated
+		targets := make([]any, 0, len(errorStructs))
+		for _, e := range errorStructs {
+			targets = append(targets, e)
+		}
+		if err := c.Unmarshal(resp.Body, targets...); err != nil {
+			return false, 0, *errResponse
 		}
 
 		errs := make([]error, 0)
@@ -240,33 +819,202 @@ func (c *client) Do(ctx context.Context, request Request, response interface{})
 		errResponse.Parent = errors.Join(errs...)
 
 		span.RecordError(err, trace.WithStackTrace(true))
-		return *errResponse
+		return false, 0, *errResponse
+	}
+
+	if cacheKey != "" && !fromCache {
+		authorized := (!skipAuth && auth.authType != authTypeNone) || req.Header.Get("Authorization") != ""
+		c.storeCache(cacheKey, req, resp, authorized)
+	}
+
+	if reqWithHeaders, ok := request.(RequestWithResponseHeaders); ok {
+		reqWithHeaders.SetResponseHeaders(resp.Header)
+	}
+
+	if raw, ok := response.(*rawResponse); ok {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			span.RecordError(err, trace.WithStackTrace(true))
+			return false, 0, NewErrorResponse("failed to read response body", resp, err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		raw.body = body
+		raw.response = resp
+		return false, 0, nil
 	}
 
 	if response == nil {
-		return nil
+		return false, 0, nil
 	}
 
-	possibleStructs := []any{response}
-	for _, e := range errorStructs {
-		possibleStructs = append(possibleStructs, &e)
+	if !isDecodable(resp, mediaType) {
+		err := c.unexpectedContentType("failed to unmarshal response", resp)
+		span.RecordError(err, trace.WithStackTrace(true))
+		return false, 0, err
+	}
+
+	// error structs are only decoded from error responses, a success body sharing their field names is no error
+	var body io.Reader = resp.Body
+	if c.responseUnwrapper != nil {
+		raw, err := io.ReadAll(resp.Body)
+		if err != nil {
+			span.RecordError(err, trace.WithStackTrace(true))
+			return false, 0, NewErrorResponse("failed to read response body", resp, err)
+		}
+		unwrapped, err := c.responseUnwrapper(raw)
+		if err != nil {
+			span.RecordError(err, trace.WithStackTrace(true))
+			return false, 0, NewErrorResponse("failed to unwrap response", resp, err)
+		}
+		body = bytes.NewReader(unwrapped)
+	}
+	if dynamic, ok := response.(*dynamicResponse); ok {
+		err = c.unmarshalDynamic(body, &dynamic.value)
+	} else {
+		err = c.Unmarshal(body, response)
 	}
-	if err := c.Unmarshal(resp.Body, possibleStructs...); err != nil {
+	if err != nil {
 		span.RecordError(err, trace.WithStackTrace(true))
-		return NewErrorResponse("failed to unmarshal response", resp, err)
+		return false, 0, NewErrorResponse("failed to unmarshal response", resp, err)
 	}
 
-	// todo: untested, since our test api has no error response bodies
-	for _, e := range errorStructs {
-		if e.Error() != "" {
+	if validator, ok := response.(ResponseValidator); ok {
+		if err := validator.Validate(); err != nil {
 			span.RecordError(err, trace.WithStackTrace(true))
-			return NewErrorResponse("error in response", resp, e)
+			return false, 0, NewErrorResponse("invalid response", resp, err)
+		}
+	}
+
+	return false, 0, nil
+}
+
+// getStreamHandler returns the handler for responses that are streamed instead of decoded, or nil when the response
+// should be decoded
+func getStreamHandler(request Request, response interface{}) func(resp *http.Response) error {
+	if stream, ok := response.(*sseStream); ok {
+		return stream.handle
+	}
+	if reqWithStream, ok := request.(RequestWithStreamHandler); ok {
+		return func(resp *http.Response) error {
+			return reqWithStream.HandleStream(resp.Body)
 		}
 	}
+	return nil
+}
+
+// handleStream passes a successful response to the stream handler
+func (c *client) handleStream(ctx context.Context, span trace.Span, attempt int, req *http.Request, resp *http.Response, request Request, handler func(resp *http.Response) error) error {
+	defer resp.Body.Close()
+
+	if c.debugEnabled(ctx) {
+		dump, _ := httputil.DumpResponse(resp, false)
+		c.log(ctx, slog.LevelDebug, "http response", string(dump),
+			slog.String("http.method", req.Method),
+			slog.String("http.url", req.URL.String()),
+			slog.Int("attempt", attempt),
+			slog.Int("status_code", resp.StatusCode),
+			slog.String("dump", string(dump)),
+		)
+	}
 
+	if reqWithHeaders, ok := request.(RequestWithResponseHeaders); ok {
+		reqWithHeaders.SetResponseHeaders(resp.Header)
+	}
+
+	if err := handler(resp); err != nil {
+		span.RecordError(err, trace.WithStackTrace(true))
+		return fmt.Errorf("failed to handle response stream: %w", err)
+	}
 	return nil
 }
 
+// send performs the http call of a single attempt, guarded by the rate limiter and circuit breaker. Transport errors
+// are reported as retryable. With digest auth a challenge is answered within the same attempt
+func (c *client) send(ctx context.Context, span trace.Span, attempt int, req *http.Request, digest *digestAuth) (*http.Response, bool, error) {
+	if c.rateLimiter != nil {
+		if err := c.rateLimiter.Wait(ctx); err != nil {
+			span.RecordError(err, trace.WithStackTrace(true))
+			return nil, false, fmt.Errorf("rate limiter: %w", wrapTimeout(err))
+		}
+	}
+
+	c.getPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	if c.debugEnabled(ctx) {
+		dump := c.dumpRequest(req)
+		c.log(ctx, slog.LevelDebug, "http request", string(dump),
+			slog.String("http.method", req.Method),
+			slog.String("http.url", req.URL.String()),
+			slog.Int("attempt", attempt),
+			slog.String("dump", string(dump)),
+		)
+	}
+
+	if c.circuitBreaker != nil {
+		if err := c.allowCircuit(); err != nil {
+			span.RecordError(err, trace.WithStackTrace(true))
+			return nil, false, err
+		}
+	}
+
+	var done func(statusCode int, err error)
+	if c.metrics != nil {
+		done = c.metrics.start(ctx, req.Method)
+	}
+
+	for _, hook := range c.requestHooks {
+		hook(req)
+	}
+
+	resp, err := c.roundTrip(ctx, req)
+	if err == nil && digest != nil {
+		resp, err = digest.handleChallenge(ctx, req, resp, c.roundTrip)
+	}
+
+	if err == nil {
+		for _, hook := range c.responseHooks {
+			hook(resp)
+		}
+	}
+
+	if done != nil {
+		statusCode := 0
+		if err == nil {
+			statusCode = resp.StatusCode
+		}
+		done(statusCode, err)
+	}
+	if c.circuitBreaker != nil {
+		if err != nil && ctx.Err() != nil && !errors.Is(context.Cause(ctx), errAttemptTimeout) {
+			// the caller gave up, a per-attempt timeout on the other hand counts as a failure
+			c.cancelCircuit()
+		} else {
+			c.reportCircuit(err == nil && resp.StatusCode < http.StatusInternalServerError)
+		}
+	}
+	if err != nil {
+		span.RecordError(err, trace.WithStackTrace(true))
+
+		c.log(ctx, slog.LevelWarn, "http request failed", fmt.Sprintf("Request failed: %s", err.Error()),
+			slog.String("http.method", req.Method),
+			slog.String("http.url", req.URL.String()),
+			slog.Int("attempt", attempt),
+			slog.String("error", err.Error()),
+		)
+		return nil, true, fmt.Errorf("failed to do http request: %w", wrapTimeout(err))
+	}
+	return resp, false, nil
+}
+
+// Marshal encodes v like request bodies are encoded, with the configured codec or as JSON with the client's jsoniter
+// config
+func (c *client) Marshal(v any) ([]byte, error) {
+	if c.codec != nil {
+		return c.codec.Marshal(v)
+	}
+	return c.GetJsoniter().Marshal(v)
+}
+
 func (c *client) Unmarshal(r io.Reader, vv ...interface{}) error {
 	if len(vv) == 0 {
 		return nil
@@ -279,7 +1027,12 @@ func (c *client) Unmarshal(r io.Reader, vv ...interface{}) error {
 
 	var errs []error
 	for _, v := range vv {
-		err := c.GetJsoniter().Unmarshal(b, &v)
+		var err error
+		if c.codec != nil {
+			err = c.codec.Unmarshal(b, v)
+		} else {
+			err = c.GetJsoniter().Unmarshal(b, v)
+		}
 		if err != nil && !errors.Is(err, io.EOF) {
 			errs = append(errs, err)
 		}
@@ -298,6 +1051,16 @@ func (c *client) Unmarshal(r io.Reader, vv ...interface{}) error {
 	return nil
 }
 
+// isReservedHeader reports whether the header is one the client sets itself, these are replaced instead of appended to
+func isReservedHeader(key string) bool {
+	switch http.CanonicalHeaderKey(key) {
+	case "Content-Type", "Accept", "User-Agent", "Authorization":
+		return true
+	default:
+		return false
+	}
+}
+
 func checkForErrorResponse(r *http.Response) *ErrorResponse {
 	if r.StatusCode >= 200 && r.StatusCode <= 299 {
 		return nil
@@ -307,85 +1070,295 @@ func checkForErrorResponse(r *http.Response) *ErrorResponse {
 	return &err
 }
 
-func getHttpRequest(ctx context.Context, request Request, baseUrl url.URL) (*http.Request, error) {
-	pathParams := getTaggedFields(request, "path")
-	queryParams := getTaggedFields(request, "query")
-
-	parsed, err := url.Parse(request.PathTemplate())
+// captureErrorBody keeps the start of the response body in the error and re-buffers the body, so it can still be read
+func (c *client) captureErrorBody(resp *http.Response, errResponse *ErrorResponse) error {
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
 	if err != nil {
-		return nil, fmt.Errorf("invalid path template: %w", err)
+		return err
 	}
 
-	requestUrl := baseUrl
-	q := requestUrl.Query()
-	for k, vv := range parsed.Query() {
-		for _, v := range vv {
-			q.Add(k, v)
-		}
-	}
-	for k, v := range queryParams {
-		q.Add(k, fmt.Sprintf("%v", v))
+	if int64(len(body)) > c.maxErrorBodyBytes {
+		body = body[:max(c.maxErrorBodyBytes, 0)]
 	}
-	requestUrl.RawQuery = q.Encode()
-	requestUrl.Path = path.Join(requestUrl.Path, parsed.Path)
+	errResponse.body = body
+	return nil
+}
+
+func (c *client) getHttpRequest(ctx context.Context, request Request) (*http.Request, error) {
+	pathParams := getTaggedFields(request, "path")
+	queryParams := getTaggedFieldList(request, "query")
 
+	pathTemplate := request.PathTemplate()
 	if len(pathParams) > 0 {
-		tmpl, err := template.New("path").Parse(requestUrl.Path)
+		tmpl, err := template.New("path").Option("missingkey=error").Parse(pathTemplate)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse path template: %w", err)
 		}
 
 		buf := new(bytes.Buffer)
-		if err = tmpl.Execute(buf, pathParams); err != nil {
-			log.Fatal(err)
+		// values are escaped so they can't change the structure of the url
+		escaped := make(map[string]string, len(pathParams))
+		for k, v := range pathParams {
+			escaped[k] = url.PathEscape(formatQueryValue(v, nil))
 		}
+		if err = tmpl.Execute(buf, escaped); err != nil {
+			return nil, fmt.Errorf("failed to execute path template: %w", err)
+		}
+
+		pathTemplate = buf.String()
+	}
+
+	parsed, err := url.Parse(pathTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template: %w", err)
+	}
+
+	requestUrl := *c.getBaseURL()
+	if c.preserveTemplateQuery {
+		// the queries of the base url and template are kept verbatim, only the tagged params are encoded
+		q := url.Values{}
+		for _, f := range queryParams {
+			addQueryParam(q, f)
+		}
+		requestUrl.RawQuery = joinQuery(requestUrl.RawQuery, parsed.RawQuery, c.encodeQuery(q))
+	} else {
+		q := requestUrl.Query()
+		for k, vv := range parsed.Query() {
+			for _, v := range vv {
+				q.Add(k, v)
+			}
+		}
+		for _, f := range queryParams {
+			addQueryParam(q, f)
+		}
+		requestUrl.RawQuery = c.encodeQuery(q)
+	}
 
-		requestUrl.Path = buf.String()
+	// join the escaped paths, so encoded slashes in either of them are kept
+	rawPath := joinPath(joinPath(requestUrl.EscapedPath(), c.basePath), parsed.EscapedPath())
+	if requestUrl.Path, err = url.PathUnescape(rawPath); err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
 	}
+	requestUrl.RawPath = rawPath
 
-	body, err := getRequestBody(request)
+	if attempt, _ := AttemptFromContext(ctx); attempt > 0 {
+		if err := c.rewindBody(request); err != nil {
+			return nil, err
+		}
+	}
+	body, bodyHeader, err := c.getRequestBody(request)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, request.Method(), requestUrl.String(), body)
+	method := request.Method()
+	if c.methodOverrideHeader != "" && method != http.MethodPost {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequestWithContext(ctx, method, requestUrl.String(), body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new http request: %w", err)
 	}
+	if method != request.Method() {
+		req.Header.Set(c.methodOverrideHeader, request.Method())
+	}
+	// the length only applies to reader bodies as is, the client knows the length of the bodies it encodes itself
+	if reqWithLength, ok := request.(RequestWithContentLength); ok && body != nil && c.isReaderBody(request) && reqWithLength.ContentLength() >= 0 {
+		req.ContentLength = reqWithLength.ContentLength()
+		if req.ContentLength == 0 {
+			req.Body = http.NoBody
+		}
+	}
+	for k := range bodyHeader {
+		req.Header.Set(k, bodyHeader.Get(k))
+	}
 	return req, nil
 }
 
-func getRequestBody(r Request) (io.Reader, error) {
-	var body io.Reader
+func (c *client) encodeQuery(q url.Values) string {
+	if c.queryEncoder != nil {
+		return c.queryEncoder(q)
+	}
+	return q.Encode()
+}
 
-	if rb, ok := r.(RequestWithBody); ok {
-		switch b := rb.Body().(type) {
-		case io.Reader:
-			body = b
-		case []byte:
-			body = bytes.NewReader(b)
-		case string:
-			body = bytes.NewReader([]byte(b))
-		default:
-			buf := new(bytes.Buffer)
-			err := jsoniter.NewEncoder(buf).Encode(rb.Body())
-			if err != nil {
-				return nil, fmt.Errorf("failed to encode request body: %w", err)
-			}
-			body = buf
+// joinQuery joins the non-empty raw queries with &
+func joinQuery(queries ...string) string {
+	nonEmpty := make([]string, 0, len(queries))
+	for _, q := range queries {
+		if q != "" {
+			nonEmpty = append(nonEmpty, q)
 		}
 	}
-	return body, nil
+	return strings.Join(nonEmpty, "&")
+}
+
+// joinPath joins the base and request path with a single slash, unlike path.Join it doesn't clean the result, keeping
+// trailing slashes and dot segments
+func joinPath(base, p string) string {
+	if p == "" {
+		return base
+	}
+	if base == "" {
+		return p
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(p, "/")
+}
+
+// getRequestBody returns the encoded body of the request and the headers that describe it, like a content type that
+// should be used instead of the client's default. Bodies that aren't readers, bytes or strings are encoded with the
+// configured codec, or as JSON when no codec is configured. Only bodies encoded by the client itself are compressed
+func (c *client) getRequestBody(r Request) (io.Reader, http.Header, error) {
+	header := http.Header{}
+
+	requestBody, ok := c.requestBody(r)
+	if !ok {
+		return nil, header, nil
+	}
+
+	var encoded []byte
+	switch b := requestBody.(type) {
+	case io.Reader:
+		return b, header, nil
+	case []byte:
+		return bytes.NewReader(b), header, nil
+	case string:
+		return bytes.NewReader([]byte(b)), header, nil
+	case MultipartBody:
+		mr := newMultipartReader(b)
+		header.Set("Content-Type", mr.ContentType())
+		return mr, header, nil
+	case *MultipartBody:
+		mr := newMultipartReader(*b)
+		header.Set("Content-Type", mr.ContentType())
+		return mr, header, nil
+	case url.Values:
+		encoded = []byte(b.Encode())
+		header.Set("Content-Type", formMediaType)
+	default:
+		var err error
+		encoded, err = c.Marshal(requestBody)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode request body: %w", err)
+		}
+	}
+
+	return c.compressRequestBody(encoded, header)
+}
+
+// requestBody returns the body of the request, ok is false when the request has no body or it isn't sent
+func (c *client) requestBody(r Request) (body any, ok bool) {
+	rb, ok := r.(RequestWithBody)
+	if !ok {
+		return nil, false
+	}
+	if !c.allowBodyOnGet && (r.Method() == http.MethodGet || r.Method() == http.MethodHead) {
+		return nil, false
+	}
+	return rb.Body(), true
+}
+
+// isReaderBody reports whether the body is an io.Reader that is sent as is
+func (c *client) isReaderBody(r Request) bool {
+	body, ok := c.requestBody(r)
+	if !ok {
+		return false
+	}
+	_, ok = body.(io.Reader)
+	return ok
+}
+
+// canRewindBody reports whether the body can be sent again by a retry. Readers, the files of a MultipartBody
+// included, can only be rewound when they implement io.Seeker but not io.Closer, closers are closed once sent
+func (c *client) canRewindBody(r Request) bool {
+	body, ok := c.requestBody(r)
+	if !ok {
+		return true
+	}
+	switch b := body.(type) {
+	case MultipartBody:
+		return b.canRewind()
+	case *MultipartBody:
+		return b.canRewind()
+	case io.Reader:
+		return canRewindReader(b)
+	default:
+		return true
+	}
+}
+
+// rewindBody seeks the readers of the body back to their start, before they're sent again by a retry
+func (c *client) rewindBody(r Request) error {
+	body, ok := c.requestBody(r)
+	if !ok {
+		return nil
+	}
+	switch b := body.(type) {
+	case MultipartBody:
+		return b.rewind()
+	case *MultipartBody:
+		return b.rewind()
+	case io.Reader:
+		return rewindReader(b)
+	default:
+		return nil
+	}
+}
+
+func canRewindReader(r io.Reader) bool {
+	_, seeker := r.(io.Seeker)
+	_, closer := r.(io.Closer)
+	return seeker && !closer
+}
+
+func rewindReader(r io.Reader) error {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return errors.New("request body can't be rewound")
+	}
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind request body: %w", err)
+	}
+	return nil
 }
 
 type isZeroer interface {
 	IsZero() bool
 }
 
+type taggedField struct {
+	name    string
+	value   interface{}
+	options []string
+}
+
 func getTaggedFields(elem interface{}, tag string) map[string]interface{} {
 	fields := make(map[string]interface{})
-	v := reflect.ValueOf(elem)
+	for _, f := range getTaggedFieldList(elem, tag) {
+		fields[f.name] = f.value
+	}
+	return fields
+}
+
+// getTaggedFieldList returns the fields tagged with the given tag in declaration order, including the tag options
+// following the name. Zero values are left out with omitempty, unless the field is a pointer or has the always option.
+// Untagged struct fields are flattened into the list, tagged ones too with their name as prefix, so a field tagged
+// filter containing one tagged status results in filter.status
+func getTaggedFieldList(elem interface{}, tag string) []taggedField {
+	return appendTaggedFields(make([]taggedField, 0), reflect.ValueOf(elem), tag, "", make(map[uintptr]bool))
+}
+
+// appendTaggedFields appends the tagged fields of the struct v points to, visiting holds the pointers of the structs
+// being flattened so cyclic data doesn't recurse forever
+func appendTaggedFields(fields []taggedField, v reflect.Value, tag string, prefix string, visiting map[uintptr]bool) []taggedField {
 	for v.Kind() == reflect.Pointer {
+		if v.IsNil() || visiting[v.Pointer()] {
+			return fields
+		}
+		visiting[v.Pointer()] = true
+		defer delete(visiting, v.Pointer())
 		v = v.Elem()
 	}
 	if v.Kind() != reflect.Struct {
@@ -394,39 +1367,201 @@ func getTaggedFields(elem interface{}, tag string) map[string]interface{} {
 	t := v.Type()
 	for i := 0; i < t.NumField(); i++ {
 		field := v.Field(i)
-		if tagValue, ok := t.Field(i).Tag.Lookup(tag); ok {
-			parts := strings.Split(tagValue, ",")
-			tagValue = parts[0]
+		tagValue, ok := t.Field(i).Tag.Lookup(tag)
+		if !ok {
+			if isNestedStruct(field.Type()) {
+				fields = appendTaggedFields(fields, field, tag, prefix, visiting)
+			}
+			continue
+		}
+		if !field.CanInterface() {
+			continue
+		}
+
+		parts := strings.Split(tagValue, ",")
+		tagValue = prefix + parts[0]
+		if isNestedStruct(field.Type()) {
+			fields = appendTaggedFields(fields, field, tag, tagValue+".", visiting)
+			continue
+		}
 
-			if field.Kind() == reflect.Pointer && field.IsNil() {
+		// a nil pointer is absent, a pointer to a zero value is present, even with omitempty
+		isPointer := field.Kind() == reflect.Pointer
+		if isPointer && field.IsNil() {
+			continue
+		} else if isPointer {
+			field = field.Elem()
+		}
+		raw := field.Interface()
+		if !isPointer && slices.Contains(parts, "omitempty") && !slices.Contains(parts, "always") {
+			if field.IsZero() {
 				continue
-			} else if field.Kind() == reflect.Pointer {
-				field = field.Elem()
 			}
-			raw := field.Interface()
-			if slices.Contains(parts, "omitempty") {
-				if field.IsZero() {
-					continue
-				}
-				if zeroer, ok := raw.(isZeroer); ok && zeroer.IsZero() {
-					continue
-				}
+			if (field.Kind() == reflect.Slice || field.Kind() == reflect.Map) && field.Len() == 0 {
+				continue
+			}
+			if zeroer, ok := raw.(isZeroer); ok && zeroer.IsZero() {
+				continue
 			}
-			fields[tagValue] = raw
 		}
+		fields = append(fields, taggedField{
+			name:    tagValue,
+			value:   raw,
+			options: parts[1:],
+		})
 	}
 
 	return fields
 }
 
+// isNestedStruct reports whether fields of the type should be flattened, structs that format themselves (like
+// time.Time) are used as a value instead
+func isNestedStruct(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for _, i := range []reflect.Type{stringerType, textMarshalerType} {
+		if t.Implements(i) || reflect.PointerTo(t).Implements(i) {
+			return false
+		}
+	}
+	return t != timeType
+}
+
+var (
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// formatHeaderValue formats a header field like a query parameter, except times which use the HTTP date format
+// unless the field has a format option
+func formatHeaderValue(v interface{}, options []string) string {
+	if t, ok := v.(time.Time); ok && !slices.ContainsFunc(options, func(option string) bool {
+		return strings.HasPrefix(option, "format=")
+	}) {
+		return t.UTC().Format(http.TimeFormat)
+	}
+	return formatQueryValue(v, options)
+}
+
+// addQueryParam adds the field to the query. Slices and arrays are added as a repeated parameter, or as a single comma
+// separated value when the field has the comma option
+func addQueryParam(q url.Values, f taggedField) {
+	v := reflect.ValueOf(f.value)
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+		q.Add(f.name, formatQueryValue(f.value, f.options))
+		return
+	}
+
+	values := make([]string, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		elem := v.Index(i)
+		if elem.Kind() == reflect.Pointer {
+			if elem.IsNil() {
+				continue
+			}
+			elem = elem.Elem()
+		}
+		values = append(values, formatQueryValue(elem.Interface(), f.options))
+	}
+
+	if slices.Contains(f.options, "comma") {
+		q.Add(f.name, strings.Join(values, ","))
+		return
+	}
+	for _, value := range values {
+		q.Add(f.name, value)
+	}
+}
+
+// formatQueryValue formats times as RFC 3339 unless the field has a format=<layout> option, other values with their
+// MarshalText or String method if they have one, or with %v
+func formatQueryValue(v interface{}, options []string) string {
+	if t, ok := v.(time.Time); ok {
+		layout := time.RFC3339
+		for _, option := range options {
+			if format, ok := strings.CutPrefix(option, "format="); ok {
+				layout = format
+			}
+		}
+		return t.Format(layout)
+	}
+	if m, ok := v.(encoding.TextMarshaler); ok {
+		if text, err := m.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	if stringer, ok := v.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func (c *client) getName() string {
+	if c.name == "" {
+		return defaultName
+	}
+	return c.name
+}
+
+func (c *client) tracer() trace.Tracer {
+	return c.getTracerProvider().Tracer(c.getName())
+}
+
+func (c *client) getTracerProvider() trace.TracerProvider {
+	if c.tracerProvider == nil {
+		return otel.GetTracerProvider()
+	}
+	return c.tracerProvider
+}
+
+func (c *client) getPropagator() propagation.TextMapPropagator {
+	if c.propagator == nil {
+		return otel.GetTextMapPropagator()
+	}
+	return c.propagator
+}
+
+// GetJsoniter returns the jsoniter instance used to encode request bodies and decode responses. It is built on first
+// use, which can happen from concurrent requests
 func (c *client) GetJsoniter() jsoniter.API {
+	c.jsoniterLock.Lock()
+	defer c.jsoniterLock.Unlock()
+
 	if c.jsoniterInstance == nil {
-		c.jsoniterInstance = jsoniter.Config{
-			EscapeHTML:             true,
-			SortMapKeys:            true,
-			ValidateJsonRawMessage: true,
-			DisallowUnknownFields:  c.disallowUnknownFields,
-		}.Froze()
+		config := c.getJsoniterConfig()
+		config.DisallowUnknownFields = config.DisallowUnknownFields || c.disallowUnknownFields
+		c.jsoniterInstance = config.Froze()
 	}
 	return c.jsoniterInstance
 }
+
+// getDynamicJsoniter returns the jsoniter instance DoJSON decodes with, the client's config without
+// WithDisallowUnknownFields
+func (c *client) getDynamicJsoniter() jsoniter.API {
+	c.jsoniterLock.Lock()
+	defer c.jsoniterLock.Unlock()
+
+	if c.dynamicJsoniter == nil {
+		config := c.getJsoniterConfig()
+		config.DisallowUnknownFields = false
+		c.dynamicJsoniter = config.Froze()
+	}
+	return c.dynamicJsoniter
+}
+
+// getJsoniterConfig returns the config set by WithJsoniterConfig, or the default one
+func (c *client) getJsoniterConfig() jsoniter.Config {
+	if c.jsoniterConfig != nil {
+		return *c.jsoniterConfig
+	}
+	return jsoniter.Config{
+		EscapeHTML:             true,
+		SortMapKeys:            true,
+		ValidateJsonRawMessage: true,
+	}
+}
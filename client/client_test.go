@@ -0,0 +1,635 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type (
+	testRequest struct {
+		method  string
+		path    string
+		headers http.Header
+	}
+
+	testBodyRequest struct {
+		testRequest
+		body any
+	}
+)
+
+func (r testRequest) Method() string {
+	return r.method
+}
+
+func (r testRequest) PathTemplate() string {
+	return r.path
+}
+
+func (r testRequest) Headers() http.Header {
+	return r.headers
+}
+
+func (r testBodyRequest) Body() any {
+	return r.body
+}
+
+// newTestClient starts a server for handler and returns a client with the server as base URL
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewClient(append([]Option{WithBaseURLString(srv.URL)}, opts...)...)
+}
+
+// withoutBackoff retries without waiting
+func withoutBackoff() Option {
+	return WithBackoff(func(int) time.Duration { return 0 })
+}
+
+func writeJSON(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(body))
+}
+
+type lengthRequest struct {
+	testBodyRequest
+	length int64
+}
+
+func (r lengthRequest) ContentLength() int64 {
+	return r.length
+}
+
+func TestContentLengthOnlyAppliesToReaderBodies(t *testing.T) {
+	var lengths []int64
+	var bodies []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		lengths = append(lengths, r.ContentLength)
+		bodies = append(bodies, string(b))
+		writeJSON(w, http.StatusOK, `{}`)
+	})
+
+	ctx := context.Background()
+	reader := lengthRequest{testBodyRequest{testRequest{method: http.MethodPost, path: "/"}, strings.NewReader("abc")}, 3}
+	if err := c.Do(ctx, reader, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	encoded := lengthRequest{testBodyRequest{testRequest{method: http.MethodPost, path: "/"}, map[string]string{"a": "b"}}, 3}
+	if err := c.Do(ctx, encoded, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if lengths[0] != 3 || bodies[0] != "abc" {
+		t.Fatalf("expected the reader body with length 3, got %q with length %d", bodies[0], lengths[0])
+	}
+	if bodies[1] != `{"a":"b"}` || lengths[1] != int64(len(bodies[1])) {
+		t.Fatalf("expected the encoded body with its own length, got %q with length %d", bodies[1], lengths[1])
+	}
+}
+
+type headerTagRequest struct {
+	testRequest
+	IfModifiedSince time.Time `header:"If-Modified-Since,omitempty"`
+	RequestID       string    `header:"X-Request-Id,omitempty"`
+}
+
+func TestHeaderTagsFormatTimesAsHTTPDates(t *testing.T) {
+	var got string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("If-Modified-Since")
+		writeJSON(w, http.StatusOK, `{}`)
+	})
+
+	req := headerTagRequest{
+		testRequest:     testRequest{method: http.MethodGet, path: "/"},
+		IfModifiedSince: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err := c.Do(context.Background(), req, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "Tue, 02 Jan 2024 03:04:05 GMT"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestHeaderTagsOverrideDefaultHeaders(t *testing.T) {
+	var got []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Values("X-Request-Id")
+		writeJSON(w, http.StatusOK, `{}`)
+	}, WithDefaultHeader("X-Request-Id", "default"))
+
+	req := headerTagRequest{testRequest: testRequest{method: http.MethodGet, path: "/"}, RequestID: "per-request"}
+	if err := c.Do(context.Background(), req, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0] != "per-request" {
+		t.Fatalf("expected only the tagged value, got %q", got)
+	}
+}
+
+func TestJsoniterConfigIsUsedForRequestBodies(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{name: "default escapes html", want: `{"html":"\u003cb\u003e"}`},
+		{name: "without html escaping", opts: []Option{WithJsoniterConfig(jsoniter.Config{EscapeHTML: false})}, want: `{"html":"<b>"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				got = string(b)
+				writeJSON(w, http.StatusOK, `{}`)
+			}, tt.opts...)
+
+			req := testBodyRequest{testRequest{method: http.MethodPost, path: "/"}, map[string]string{"html": "<b>"}}
+			if err := c.Do(context.Background(), req, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+// BenchmarkBasicAuth compares encoding the credentials on every request, as SetBasicAuth does, with setting the header
+// WithBasicAuth encodes once
+func BenchmarkBasicAuth(b *testing.B) {
+	c := NewClient(WithBasicAuth("username", "a-fairly-long-password")).(*client)
+
+	b.Run("per request", func(b *testing.B) {
+		req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+		b.ReportAllocs()
+		for b.Loop() {
+			req.SetBasicAuth(c.userName, c.password)
+		}
+	})
+	b.Run("precomputed", func(b *testing.B) {
+		req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+		b.ReportAllocs()
+		for b.Loop() {
+			req.Header.Set("Authorization", c.basicAuthHeader)
+		}
+	})
+}
+
+type pathRequest struct {
+	testRequest
+	ID string `path:"id"`
+}
+
+func TestPathTemplateWithMissingFieldReturnsError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL)
+	})
+
+	req := pathRequest{testRequest: testRequest{method: http.MethodGet, path: "/users/{{.id}}/orders/{{.order}}"}, ID: "1"}
+	err := c.Do(context.Background(), req, nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to execute path template") {
+		t.Fatalf("expected a path template error, got %v", err)
+	}
+}
+
+func TestRequestAcceptHeaderIsSentOnce(t *testing.T) {
+	var got []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Values("Accept")
+		writeJSON(w, http.StatusOK, `{}`)
+	}, WithDefaultHeader("Accept", "application/xml"))
+
+	req := testRequest{method: http.MethodGet, path: "/", headers: http.Header{"Accept": {"application/vnd.api+json"}}}
+	if err := c.Do(context.Background(), req, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0] != "application/vnd.api+json" {
+		t.Fatalf("expected a single Accept value, got %q", got)
+	}
+}
+
+// headerRequest receives the headers of the response
+type headerRequest struct {
+	testRequest
+	header http.Header
+}
+
+func (r *headerRequest) SetResponseHeaders(header http.Header) {
+	r.header = header
+}
+
+func TestRequestPathJoin(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseURL  string
+		template string
+		want     string
+	}{
+		{name: "trailing slash", baseURL: "http://example.com/api", template: "/v1/items/", want: "/api/v1/items/"},
+		{name: "base with trailing slash", baseURL: "http://example.com/api/", template: "/v1/items", want: "/api/v1/items"},
+		{name: "both with trailing slash", baseURL: "http://example.com/api/", template: "/v1/items/", want: "/api/v1/items/"},
+		{name: "double slash in base", baseURL: "http://example.com/a//b/", template: "/v1", want: "/a//b/v1"},
+		{name: "encoded slash", baseURL: "http://example.com/api/", template: "/files/a%2Fb", want: "/api/files/a%2Fb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(WithBaseURLString(tt.baseURL)).(*client)
+			req, err := c.getHttpRequest(context.Background(), testRequest{method: http.MethodGet, path: tt.template})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := req.URL.EscapedPath(); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+type (
+	dateRange struct {
+		From string `query:"from,omitempty"`
+		To   string `query:"to,omitempty"`
+	}
+
+	orderFilter struct {
+		Status  string     `query:"status,omitempty"`
+		Created *dateRange `query:"created"`
+	}
+
+	paging struct {
+		Page int `query:"page"`
+	}
+
+	listOrdersRequest struct {
+		testRequest
+		paging
+		Filter orderFilter `query:"filter"`
+	}
+)
+
+func TestNestedQueryStructsAreFlattened(t *testing.T) {
+	c := NewClient(WithBaseURLString("http://example.com")).(*client)
+
+	req := listOrdersRequest{
+		testRequest: testRequest{method: http.MethodGet, path: "/orders"},
+		paging:      paging{Page: 2},
+		Filter:      orderFilter{Status: "open", Created: &dateRange{From: "2024-01-01"}},
+	}
+	httpReq, err := c.getHttpRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := url.Values{"page": {"2"}, "filter.status": {"open"}, "filter.created.from": {"2024-01-01"}}
+	if got := httpReq.URL.Query(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// a nil nested struct is left out
+	req.Filter.Created = nil
+	if httpReq, err = c.getHttpRequest(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = url.Values{"page": {"2"}, "filter.status": {"open"}}
+	if got := httpReq.URL.Query(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+type (
+	category struct {
+		Name   string    `query:"name"`
+		Parent *category `query:"parent"`
+		Self   *category
+	}
+
+	categoryRequest struct {
+		testRequest
+		Category *category `query:"category"`
+	}
+)
+
+func TestCyclicQueryStructsAreFlattenedOnce(t *testing.T) {
+	c := NewClient(WithBaseURLString("http://example.com")).(*client)
+
+	root := &category{Name: "root"}
+	root.Parent, root.Self = root, root
+	child := &category{Name: "child", Parent: root}
+	req := categoryRequest{testRequest: testRequest{method: http.MethodGet, path: "/"}, Category: child}
+
+	httpReq, err := c.getHttpRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := url.Values{"category.name": {"child"}, "category.parent.name": {"root"}}
+	if got := httpReq.URL.Query(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestFormatQueryValueTimes(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name    string
+		options []string
+		want    string
+	}{
+		{name: "rfc3339 by default", want: "2024-01-02T03:04:05Z"},
+		{name: "custom format", options: []string{"format=2006-01-02"}, want: "2024-01-02"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatQueryValue(ts, tt.options); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+type sinceRequest struct {
+	testRequest
+	Since time.Time `query:"since,omitempty,format=2006-01-02"`
+}
+
+func TestTimeQueryParams(t *testing.T) {
+	c := NewClient(WithBaseURLString("http://example.com")).(*client)
+
+	req := sinceRequest{testRequest: testRequest{method: http.MethodGet, path: "/"}, Since: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	httpReq, err := c.getHttpRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := httpReq.URL.RawQuery; got != "since=2024-01-02" {
+		t.Fatalf("expected since=2024-01-02, got %q", got)
+	}
+
+	// zero times are left out with omitempty
+	req.Since = time.Time{}
+	if httpReq, err = c.getHttpRequest(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := httpReq.URL.RawQuery; got != "" {
+		t.Fatalf("expected no query, got %q", got)
+	}
+}
+
+type mergePatchRequest struct {
+	testBodyRequest
+}
+
+func (mergePatchRequest) PatchType() string {
+	return MergePatchMediaType
+}
+
+func TestPatchTypeSetsContentType(t *testing.T) {
+	var contentType, accept string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		contentType, accept = r.Header.Get("Content-Type"), r.Header.Get("Accept")
+		writeJSON(w, http.StatusOK, `{}`)
+	})
+
+	req := mergePatchRequest{testBodyRequest{testRequest{method: http.MethodPatch, path: "/items/1"}, map[string]any{"name": nil}}}
+	if err := c.Do(context.Background(), req, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "application/merge-patch+json; charset=utf-8"; contentType != want {
+		t.Fatalf("expected Content-Type %q, got %q", want, contentType)
+	}
+	if accept != "application/json" {
+		t.Fatalf("expected Accept application/json, got %q", accept)
+	}
+}
+
+func TestWithoutUserAgent(t *testing.T) {
+	var present bool
+	var got string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, present = r.Header["User-Agent"]
+		got = r.Header.Get("User-Agent")
+		writeJSON(w, http.StatusOK, `{}`)
+	}, WithoutUserAgent())
+
+	if err := c.Do(context.Background(), testRequest{method: http.MethodGet, path: "/"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if present {
+		t.Fatalf("expected no User-Agent header, got %q", got)
+	}
+}
+
+func TestGetBodyIsOmittedUnlessAllowed(t *testing.T) {
+	tests := []struct {
+		name            string
+		opts            []Option
+		wantBody        string
+		wantContentType string
+	}{
+		{name: "omitted by default"},
+		{name: "allowed", opts: []Option{WithAllowBodyOnGet(true)}, wantBody: `{"q":"search"}`, wantContentType: "application/json; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body, contentType string
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				body, contentType = string(b), r.Header.Get("Content-Type")
+				writeJSON(w, http.StatusOK, `{}`)
+			}, tt.opts...)
+
+			req := testBodyRequest{testRequest{method: http.MethodGet, path: "/"}, map[string]string{"q": "search"}}
+			if err := c.Do(context.Background(), req, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if body != tt.wantBody || contentType != tt.wantContentType {
+				t.Fatalf("expected body %q with Content-Type %q, got %q with %q", tt.wantBody, tt.wantContentType, body, contentType)
+			}
+		})
+	}
+}
+
+func TestRequestWithoutBodyHasNoContentType(t *testing.T) {
+	var present bool
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, present = r.Header["Content-Type"]
+		writeJSON(w, http.StatusOK, `{}`)
+	})
+
+	if err := c.Do(context.Background(), testRequest{method: http.MethodPost, path: "/"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if present {
+		t.Fatal("expected no Content-Type header")
+	}
+}
+
+type zeroValueRequest struct {
+	testRequest
+	Active   bool  `query:"active,omitempty"`
+	Archived bool  `query:"archived,omitempty,always"`
+	Limit    *int  `query:"limit,omitempty"`
+	Deleted  *bool `query:"deleted,omitempty"`
+}
+
+func TestZeroQueryValues(t *testing.T) {
+	c := NewClient(WithBaseURLString("http://example.com")).(*client)
+
+	zero := 0
+	req := zeroValueRequest{testRequest: testRequest{method: http.MethodGet, path: "/"}, Limit: &zero}
+	httpReq, err := c.getHttpRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// omitempty drops the zero value, always keeps it, a pointer to a zero value is kept and a nil pointer dropped
+	want := url.Values{"archived": {"false"}, "limit": {"0"}}
+	if got := httpReq.URL.Query(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+type (
+	orderStatus int
+
+	// sortOrder implements both interfaces, TextMarshaler takes precedence
+	sortOrder struct {
+		field string
+		desc  bool
+	}
+)
+
+const (
+	statusOpen orderStatus = iota + 1
+	statusClosed
+)
+
+func (s orderStatus) String() string {
+	switch s {
+	case statusOpen:
+		return "open"
+	case statusClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+func (s sortOrder) MarshalText() ([]byte, error) {
+	if s.desc {
+		return []byte("-" + s.field), nil
+	}
+	return []byte(s.field), nil
+}
+
+func (s sortOrder) String() string {
+	return "sortOrder{" + s.field + "}"
+}
+
+func TestFormatQueryValueTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{name: "stringer enum", value: statusClosed, want: "closed"},
+		{name: "text marshaler", value: sortOrder{field: "created", desc: true}, want: "-created"},
+		{name: "bool", value: true, want: "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatQueryValue(tt.value, nil); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPreflightAuthCanReadTheBody(t *testing.T) {
+	var received, signed string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = string(b)
+		writeJSON(w, http.StatusOK, `{}`)
+	}, WithPreflightAuth(func(req *http.Request, _ Client) (*http.Request, error) {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		signed = string(b)
+		req.Header.Set("X-Signature", fmt.Sprintf("%x", len(b)))
+		return req, nil
+	}))
+
+	req := testBodyRequest{testRequest{method: http.MethodPost, path: "/"}, map[string]string{"a": "b"}}
+	if err := c.Do(context.Background(), req, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if signed != `{"a":"b"}` {
+		t.Fatalf("expected the preflight function to read the body, got %q", signed)
+	}
+	if received != signed {
+		t.Fatalf("expected the server to receive %q, got %q", signed, received)
+	}
+}
+
+func TestMethodOverrideAndDeleteBodies(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []Option
+		wantMethod   string
+		wantOverride string
+	}{
+		{name: "delete with body", wantMethod: http.MethodDelete},
+		{name: "method override", opts: []Option{WithMethodOverrideHeader("X-HTTP-Method-Override")}, wantMethod: http.MethodPost, wantOverride: http.MethodDelete},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, override, body string
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				method, override, body = r.Method, r.Header.Get("X-HTTP-Method-Override"), string(b)
+				writeJSON(w, http.StatusOK, `{}`)
+			}, tt.opts...)
+
+			req := testBodyRequest{testRequest{method: http.MethodDelete, path: "/items"}, map[string][]int{"ids": {1, 2}}}
+			if err := c.Do(context.Background(), req, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if method != tt.wantMethod || override != tt.wantOverride {
+				t.Fatalf("expected %s with override %q, got %s with %q", tt.wantMethod, tt.wantOverride, method, override)
+			}
+			if body != `{"ids":[1,2]}` {
+				t.Fatalf("expected the body to be sent, got %q", body)
+			}
+		})
+	}
+}
+
+func TestDoJSONIgnoresDisallowUnknownFields(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, `{"name":"value"}`)
+	}, WithDisallowUnknownFields(true))
+
+	for range 2 {
+		got, err := c.DoJSON(context.Background(), testRequest{method: http.MethodGet, path: "/"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		m, ok := got.(map[string]any)
+		if !ok || m["name"] != "value" {
+			t.Fatalf("expected the decoded body, got %#v", got)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package clienttest provides a mock transport to unit test code using the client without a server
+package clienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/omniboost/go-omniboost-http-client/client"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// BaseURL is the base URL of clients returned by NewTestClient
+const BaseURL = "http://clienttest.invalid"
+
+type (
+	// MockResponse is a canned response returned by MockTransport
+	MockResponse struct {
+		StatusCode int
+		Header     http.Header
+		Body       []byte
+	}
+
+	// RecordedRequest is a request received by MockTransport, with its body read
+	RecordedRequest struct {
+		Method string
+		Path   string
+		Query  url.Values
+		Header http.Header
+		Body   []byte
+	}
+
+	// MockTransport is an http.RoundTripper matching requests by method and path to canned responses, it records every
+	// request it receives. Requests without a matching response get a 404
+	MockTransport struct {
+		lock      sync.Mutex
+		responses map[string][]MockResponse
+		requests  []RecordedRequest
+	}
+)
+
+var _ http.RoundTripper = (*MockTransport)(nil)
+
+func NewMockTransport() *MockTransport {
+	return &MockTransport{
+		responses: make(map[string][]MockResponse),
+	}
+}
+
+// NewTestClient returns a client sending its requests to a new MockTransport, with BaseURL as base URL. The given
+// options are applied afterwards
+func NewTestClient(opts ...client.Option) (client.Client, *MockTransport) {
+	transport := NewMockTransport()
+	opts = append([]client.Option{
+		client.WithBaseURLString(BaseURL),
+		client.WithTransport(transport),
+	}, opts...)
+	return client.NewClient(opts...), transport
+}
+
+// Handle adds a response for requests with the method and path (without query). Responses added for the same request
+// are returned in order, the last one is repeated
+func (m *MockTransport) Handle(method, path string, resp MockResponse) *MockTransport {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	key := routeKey(method, path)
+	m.responses[key] = append(m.responses[key], resp)
+	return m
+}
+
+// HandleJSON adds a response with v encoded as JSON as body, see Handle
+func (m *MockTransport) HandleJSON(method, path string, statusCode int, v any) *MockTransport {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("clienttest: failed to encode response: %v", err))
+	}
+	return m.Handle(method, path, MockResponse{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       body,
+	})
+}
+
+// Requests returns the requests received so far
+func (m *MockTransport) Requests() []RecordedRequest {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return append([]RecordedRequest{}, m.requests...)
+}
+
+// Reset removes all responses and recorded requests
+func (m *MockTransport) Reset() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.responses = make(map[string][]MockResponse)
+	m.requests = nil
+}
+
+func (m *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.requests = append(m.requests, RecordedRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.Query(),
+		Header: req.Header.Clone(),
+		Body:   body,
+	})
+
+	key := routeKey(req.Method, req.URL.Path)
+	responses := m.responses[key]
+	if len(responses) == 0 {
+		return newResponse(req, MockResponse{
+			StatusCode: http.StatusNotFound,
+			Header:     http.Header{"Content-Type": []string{"text/plain"}},
+			Body:       []byte("clienttest: no response for " + key),
+		}), nil
+	}
+
+	resp := responses[0]
+	if len(responses) > 1 {
+		m.responses[key] = responses[1:]
+	}
+	return newResponse(req, resp), nil
+}
+
+func newResponse(req *http.Request, resp MockResponse) *http.Response {
+	statusCode := resp.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	header := resp.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}
+}
+
+func routeKey(method, path string) string {
+	return method + " " + path
+}
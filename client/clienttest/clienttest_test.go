@@ -0,0 +1,121 @@
+package clienttest
+
+import (
+	"context"
+	"github.com/omniboost/go-omniboost-http-client/client"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type (
+	testRequest struct {
+		method  string
+		path    string
+		headers http.Header
+	}
+
+	testBodyRequest struct {
+		testRequest
+		body any
+	}
+)
+
+func (r testRequest) Method() string {
+	return r.method
+}
+
+func (r testRequest) PathTemplate() string {
+	return r.path
+}
+
+func (r testRequest) Headers() http.Header {
+	return r.headers
+}
+
+func (r testBodyRequest) Body() any {
+	return r.body
+}
+
+func TestMockTransportMatchesMethodAndPath(t *testing.T) {
+	c, transport := NewTestClient()
+	transport.HandleJSON(http.MethodGet, "/orders", http.StatusOK, map[string]int{"id": 1})
+	transport.HandleJSON(http.MethodGet, "/orders", http.StatusOK, map[string]int{"id": 2})
+	transport.HandleJSON(http.MethodPost, "/orders", http.StatusCreated, map[string]int{"id": 3})
+
+	for _, tt := range []struct {
+		method string
+		wantID int
+	}{
+		{method: http.MethodGet, wantID: 1},
+		{method: http.MethodPost, wantID: 3},
+		{method: http.MethodGet, wantID: 2},
+		{method: http.MethodGet, wantID: 2},
+	} {
+		var resp struct {
+			ID int `json:"id"`
+		}
+		req := testBodyRequest{testRequest: testRequest{method: tt.method, path: "/orders"}, body: map[string]string{"name": "test"}}
+		if err := c.Do(context.Background(), req, &resp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.ID != tt.wantID {
+			t.Fatalf("expected id %d for %s, got %d", tt.wantID, tt.method, resp.ID)
+		}
+	}
+
+	requests := transport.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("expected 4 recorded requests, got %d", len(requests))
+	}
+	if post := requests[1]; post.Method != http.MethodPost || post.Path != "/orders" || strings.TrimSpace(string(post.Body)) != `{"name":"test"}` {
+		t.Fatalf("unexpected recorded request %s %s %q", post.Method, post.Path, post.Body)
+	}
+}
+
+func TestMockTransportRecordsHeadersAndQuery(t *testing.T) {
+	c, transport := NewTestClient()
+	transport.Handle(http.MethodGet, "/orders", MockResponse{StatusCode: http.StatusNoContent})
+
+	req := testRequest{method: http.MethodGet, path: "/orders?status=open", headers: http.Header{"X-Tenant": {"acme"}}}
+	if _, _, err := c.DoRaw(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	requests := transport.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 recorded request, got %d", len(requests))
+	}
+	if got := requests[0]; got.Path != "/orders" || got.Query.Get("status") != "open" || got.Header.Get("X-Tenant") != "acme" {
+		t.Fatalf("unexpected recorded request %+v", got)
+	}
+}
+
+func TestMockTransportRespondsNotFoundWithoutMatchingResponse(t *testing.T) {
+	c, transport := NewTestClient()
+	transport.Handle(http.MethodGet, "/orders", MockResponse{})
+
+	_, _, err := c.DoRaw(context.Background(), testRequest{method: http.MethodDelete, path: "/orders"})
+	if !client.IsNotFound(err) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "clienttest: no response for DELETE /orders") {
+		t.Fatalf("expected the unmatched route in the error, got %v", err)
+	}
+}
+
+func TestMockTransportReset(t *testing.T) {
+	c, transport := NewTestClient()
+	transport.Handle(http.MethodGet, "/orders", MockResponse{})
+	if _, _, err := c.DoRaw(context.Background(), testRequest{method: http.MethodGet, path: "/orders"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	transport.Reset()
+	if len(transport.Requests()) != 0 {
+		t.Fatalf("expected no recorded requests after Reset, got %d", len(transport.Requests()))
+	}
+	if _, _, err := c.DoRaw(context.Background(), testRequest{method: http.MethodGet, path: "/orders"}); !client.IsNotFound(err) {
+		t.Fatalf("expected a not found error after Reset, got %v", err)
+	}
+}
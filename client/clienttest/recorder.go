@@ -0,0 +1,197 @@
+package clienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+const (
+	// ModeReplay serves responses from the cassette, requests without a recorded interaction fail
+	ModeReplay Mode = iota
+	// ModeRecord sends requests upstream and records them, Save writes the cassette
+	ModeRecord
+)
+
+var defaultScrubbedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+type (
+	Mode int
+
+	// Interaction is a recorded request and its response
+	Interaction struct {
+		Request  RecordedRequest
+		Response MockResponse
+	}
+
+	// Recorder is an http.RoundTripper recording interactions with an upstream to a cassette file, or replaying them.
+	// Recorded requests are matched on method, path and, when set, the body matcher
+	Recorder struct {
+		path            string
+		mode            Mode
+		upstream        http.RoundTripper
+		matchBody       func(recorded, actual []byte) bool
+		scrubbedHeaders []string
+
+		lock         sync.Mutex
+		interactions []Interaction
+		used         []bool
+	}
+
+	RecorderOption func(*Recorder)
+)
+
+var _ http.RoundTripper = (*Recorder)(nil)
+
+// WithUpstream sets the round tripper requests are recorded from, defaults to http.DefaultTransport
+func WithUpstream(rt http.RoundTripper) RecorderOption {
+	return func(r *Recorder) {
+		r.upstream = rt
+	}
+}
+
+// WithBodyMatcher additionally matches requests on their body when replaying
+func WithBodyMatcher(match func(recorded, actual []byte) bool) RecorderOption {
+	return func(r *Recorder) {
+		r.matchBody = match
+	}
+}
+
+// WithScrubbedHeaders adds headers left out of the cassette, next to Authorization, Proxy-Authorization, Cookie and
+// Set-Cookie
+func WithScrubbedHeaders(keys ...string) RecorderOption {
+	return func(r *Recorder) {
+		r.scrubbedHeaders = append(r.scrubbedHeaders, keys...)
+	}
+}
+
+// NewRecorder returns a recorder for the cassette at path, in replay mode the cassette is loaded
+func NewRecorder(path string, mode Mode, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		path:            path,
+		mode:            mode,
+		upstream:        http.DefaultTransport,
+		scrubbedHeaders: append([]string{}, defaultScrubbedHeaders...),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if mode == ModeReplay {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("clienttest: failed to read cassette: %w", err)
+		}
+		if err := json.Unmarshal(b, &r.interactions); err != nil {
+			return nil, fmt.Errorf("clienttest: failed to decode cassette: %w", err)
+		}
+		r.used = make([]bool, len(r.interactions))
+	}
+	return r, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == ModeRecord {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+// Save writes the recorded interactions to the cassette, it does nothing in replay mode
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	b, err := json.MarshalIndent(r.interactions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("clienttest: failed to encode cassette: %w", err)
+	}
+	if err := os.WriteFile(r.path, b, 0o644); err != nil {
+		return fmt.Errorf("clienttest: failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.upstream.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("clienttest: failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.interactions = append(r.interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			Path:   req.URL.Path,
+			Query:  req.URL.Query(),
+			Header: r.scrub(req.Header),
+			Body:   body,
+		},
+		Response: MockResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.scrub(resp.Header),
+			Body:       respBody,
+		},
+	})
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Request.Method != req.Method || interaction.Request.Path != req.URL.Path {
+			continue
+		}
+		if r.matchBody != nil && !r.matchBody(interaction.Request.Body, body) {
+			continue
+		}
+		r.used[i] = true
+		return newResponse(req, interaction.Response), nil
+	}
+	return nil, errors.New("clienttest: no recorded interaction for " + routeKey(req.Method, req.URL.Path))
+}
+
+func (r *Recorder) scrub(header http.Header) http.Header {
+	scrubbed := header.Clone()
+	for _, key := range r.scrubbedHeaders {
+		scrubbed.Del(key)
+	}
+	return scrubbed
+}
+
+// readBody reads the request body and replaces it, so it can still be sent
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("clienttest: failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,115 @@
+package clienttest
+
+import (
+	"bytes"
+	"context"
+	"github.com/omniboost/go-omniboost-http-client/client"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// record sends requests through a recorder in record mode to a test server and saves the cassette
+func record(t *testing.T, path string, opts ...RecorderOption) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Request-Id", "123")
+		_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	recorder, err := NewRecorder(path, ModeRecord, append([]RecorderOption{WithUpstream(srv.Client().Transport)}, opts...)...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := client.NewClient(client.WithBaseURLString(srv.URL), client.WithTransport(recorder), client.WithBearerToken("secret"))
+	for _, p := range []string{"/orders", "/customers"} {
+		req := testRequest{method: http.MethodGet, path: p, headers: http.Header{"X-Api-Key": {"secret"}}}
+		if _, _, err := c.DoRaw(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := recorder.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRecorderReplaysRecordedInteractions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	record(t, path)
+
+	recorder, err := NewRecorder(path, ModeReplay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := client.NewClient(client.WithBaseURLString(BaseURL), client.WithTransport(recorder))
+	for _, p := range []string{"/customers", "/orders"} {
+		body, resp, err := c.DoRaw(context.Background(), testRequest{method: http.MethodGet, path: p})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(body) != `{"path":"`+p+`"}` || resp.Header.Get("X-Request-Id") != "123" {
+			t.Fatalf("unexpected replayed response %q with headers %v", body, resp.Header)
+		}
+	}
+
+	// every interaction is replayed once
+	_, _, err = c.DoRaw(context.Background(), testRequest{method: http.MethodGet, path: "/orders"})
+	if err == nil || !strings.Contains(err.Error(), "clienttest: no recorded interaction for GET /orders") {
+		t.Fatalf("expected the unmatched interaction error, got %v", err)
+	}
+}
+
+func TestRecorderScrubsHeaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	record(t, path, WithScrubbedHeaders("X-Api-Key"))
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bytes.Contains(b, []byte("secret")) {
+		t.Fatalf("expected secrets to be scrubbed from the cassette, got %s", b)
+	}
+	if !bytes.Contains(b, []byte("X-Request-Id")) {
+		t.Fatalf("expected other headers to be kept in the cassette, got %s", b)
+	}
+}
+
+func TestRecorderMatchesBody(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	interactions := `[
+		{"Request": {"Method": "POST", "Path": "/orders", "Body": "eyJpZCI6MX0="}, "Response": {"StatusCode": 200, "Body": "Zmlyc3Q="}},
+		{"Request": {"Method": "POST", "Path": "/orders", "Body": "eyJpZCI6Mn0="}, "Response": {"StatusCode": 200, "Body": "c2Vjb25k"}}
+	]`
+	if err := os.WriteFile(path, []byte(interactions), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	recorder, err := NewRecorder(path, ModeReplay, WithBodyMatcher(func(recorded, actual []byte) bool {
+		return bytes.Equal(recorded, bytes.TrimSpace(actual))
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := client.NewClient(client.WithBaseURLString(BaseURL), client.WithTransport(recorder))
+	req := testBodyRequest{testRequest: testRequest{method: http.MethodPost, path: "/orders"}, body: map[string]int{"id": 2}}
+	body, _, err := c.DoRaw(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(body) != "second" {
+		t.Fatalf("expected the interaction with the matching body, got %q", body)
+	}
+}
+
+func TestNewRecorderWithoutCassette(t *testing.T) {
+	if _, err := NewRecorder(filepath.Join(t.TempDir(), "missing.json"), ModeReplay); err == nil {
+		t.Fatal("expected an error for a missing cassette")
+	}
+}
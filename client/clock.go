@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is the source of time for retries, caching, token expiry, signatures and the circuit breaker,
+// tests can replace it to control time
+type Clock interface {
+	Now() time.Time
+	// Sleep waits for the duration, returning early with the context error if the context is done first
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+type realClock struct{}
+
+var _ Clock = realClock{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	return sleepContext(ctx, d)
+}
+
+func (c *client) getClock() Clock {
+	if c.clock == nil {
+		return realClock{}
+	}
+	return c.clock
+}
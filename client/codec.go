@@ -0,0 +1,105 @@
+package client
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+type (
+	// Codec encodes request bodies and decodes response bodies, its content type is used for the Content-Type and
+	// Accept headers
+	Codec interface {
+		Marshal(v any) ([]byte, error)
+		Unmarshal(data []byte, v any) error
+		ContentType() string
+	}
+
+	xmlCodec struct{}
+)
+
+var _ Codec = xmlCodec{}
+
+// XMLCodec returns a Codec using encoding/xml
+func XMLCodec() Codec {
+	return xmlCodec{}
+}
+
+func (xmlCodec) Marshal(v any) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Unmarshal(data []byte, v any) error {
+	return xml.Unmarshal(data, v)
+}
+
+func (xmlCodec) ContentType() string {
+	return "application/xml"
+}
+
+// isDecodable reports whether the response content type matches the expected media type, responses without a
+// content type are assumed to match
+func isDecodable(resp *http.Response, mediaType string) bool {
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+
+	got, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	expected, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		expected = mediaType
+	}
+	if got == expected {
+		return true
+	}
+
+	// structured syntax suffixes, e.g. application/problem+json and application/vnd.api+json are both json
+	return structuredSuffix(got) != "" && structuredSuffix(got) == structuredSuffix(expected)
+}
+
+// acceptMediaType returns the media type for the Accept header, the vendor media type set by WithAcceptVersion with
+// the structured syntax of mediaType, or mediaType itself
+func (c *client) acceptMediaType(mediaType string) string {
+	if c.acceptVendor == "" {
+		return mediaType
+	}
+	base, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		base = mediaType
+	}
+	subtype := "vnd." + c.acceptVendor
+	if c.acceptVersion != "" {
+		subtype += "." + c.acceptVersion
+	}
+	return fmt.Sprintf("application/%s+%s", subtype, structuredSuffix(base))
+}
+
+func structuredSuffix(mediaType string) string {
+	_, subtype, _ := strings.Cut(mediaType, "/")
+	if i := strings.LastIndex(subtype, "+"); i >= 0 {
+		return subtype[i+1:]
+	}
+	return subtype
+}
+
+// unexpectedContentType returns an ErrorResponse carrying the raw body of a response that can't be decoded
+func (c *client) unexpectedContentType(message string, resp *http.Response) ErrorResponse {
+	errResponse := NewErrorResponse(
+		fmt.Sprintf("%s: unexpected content type %s", message, resp.Header.Get("Content-Type")), resp, nil)
+	if err := c.captureErrorBody(resp, &errResponse); err != nil {
+		errResponse.Parent = err
+		return errResponse
+	}
+
+	if text := strings.TrimSpace(string(errResponse.body)); text != "" {
+		errResponse.Parent = errors.New(text)
+	}
+	return errResponse
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const defaultCompressionMinSize = 1024
+
+var defaultAcceptEncodings = []string{"gzip", "deflate", "br"}
+
+// compressRequestBody gzips the encoded body when request compression is enabled and the body is at least the
+// configured minimum size
+func (c *client) compressRequestBody(body []byte, header http.Header) (io.Reader, http.Header, error) {
+	if !c.requestCompression || len(body) < c.compressionMinSize {
+		return bytes.NewReader(body), header, nil
+	}
+
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, nil, fmt.Errorf("failed to compress request body: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to compress request body: %w", err)
+	}
+
+	header.Set("Content-Encoding", "gzip")
+	return buf, header, nil
+}
+
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	var errs []error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		errs = append(errs, b.closers[i].Close())
+	}
+	return errors.Join(errs...)
+}
+
+// decompressResponse decodes the response body according to its Content-Encoding header, for when the transport
+// didn't do so transparently. Decoded responses lose their Content-Encoding and Content-Length headers
+func decompressResponse(resp *http.Response) error {
+	var encodings []string
+	for _, v := range resp.Header.Values("Content-Encoding") {
+		for _, enc := range strings.Split(v, ",") {
+			enc = strings.ToLower(strings.TrimSpace(enc))
+			if enc != "" && enc != "identity" {
+				encodings = append(encodings, enc)
+			}
+		}
+	}
+	if len(encodings) == 0 || !hasResponseBody(resp) {
+		return nil
+	}
+
+	// an empty body is left as is, the decoders would fail on it
+	buffered := bufio.NewReader(resp.Body)
+	if _, err := buffered.Peek(1); errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	body := &decodedBody{Reader: buffered, closers: []io.Closer{resp.Body}}
+	// encodings are listed in the order they were applied, so they are decoded in reverse
+	for i := len(encodings) - 1; i >= 0; i-- {
+		r, err := newDecoder(encodings[i], body.Reader)
+		if err != nil {
+			_ = body.Close()
+			return err
+		}
+		body.Reader = r
+		body.closers = append(body.closers, r)
+	}
+
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// hasResponseBody reports whether the response can have a body, HEAD, 204 and 304 responses and responses with a zero
+// Content-Length can't
+func hasResponseBody(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+	return resp.ContentLength != 0
+}
+
+func newDecoder(encoding string, r io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode gzip response body: %w", err)
+		}
+		return zr, nil
+	case "deflate":
+		// deflate should be zlib wrapped, but plenty of servers send raw deflate data
+		br := bufio.NewReader(r)
+		if header, err := br.Peek(2); err == nil && isZlibHeader(header) {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode deflate response body: %w", err)
+			}
+			return zr, nil
+		}
+		return flate.NewReader(br), nil
+	case "br":
+		return io.NopCloser(brotli.NewReader(r)), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestDecompressSkipsResponsesWithoutBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		status int
+	}{
+		{name: "head", method: http.MethodHead, status: http.StatusOK},
+		{name: "no content", method: http.MethodGet, status: http.StatusNoContent},
+		{name: "empty body", method: http.MethodGet, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+			})
+
+			err := c.Do(context.Background(), testRequest{method: tt.method, path: "/"}, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDecompressGzipResponse(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write([]byte(`{"name":"value"}`))
+	_ = zw.Close()
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(buf.Bytes())
+	})
+
+	var resp struct {
+		Name string `json:"name"`
+	}
+	if err := c.Do(context.Background(), testRequest{method: http.MethodGet, path: "/"}, &resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Name != "value" {
+		t.Fatalf("expected the decoded body, got %q", resp.Name)
+	}
+}
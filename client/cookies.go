@@ -0,0 +1,43 @@
+package client
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+)
+
+// getHttpClient returns the http client to send requests with, its cookie jar matching the cookie options. The
+// configured client is never modified, a copy is made when its jar doesn't match. A sub-client without its own http
+// client uses the parent's, with its own cookie handling when a cookie option was set on the sub-client
+func (c *client) getHttpClient() *http.Client {
+	base := c.httpClient
+	if parent := c.parent(); !c.ownHttpClient && parent != nil {
+		if !c.cookieOption {
+			return parent.getHttpClient()
+		}
+		base = parent.getHttpClient()
+	}
+	if !c.useCookies && base.Jar == nil {
+		return base
+	}
+	if c.useCookies && c.cookieJar == nil && base.Jar != nil {
+		return base
+	}
+
+	c.cookieLock.Lock()
+	defer c.cookieLock.Unlock()
+
+	if c.cookieClient == nil || c.cookieClientBase != base || c.useCookies != (c.cookieClient.Jar != nil) {
+		httpClient := *base
+		httpClient.Jar = nil
+		if c.useCookies {
+			httpClient.Jar = c.cookieJar
+			if httpClient.Jar == nil {
+				// cookiejar.New only fails on invalid options
+				httpClient.Jar, _ = cookiejar.New(nil)
+			}
+		}
+		c.cookieClient = &httpClient
+		c.cookieClientBase = base
+	}
+	return c.cookieClient
+}
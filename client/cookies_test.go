@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// cookieHandler sets a session cookie on /login and reports the session cookie of other requests in got
+func cookieHandler(got *string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			writeJSON(w, http.StatusOK, `{}`)
+			return
+		}
+		*got = ""
+		if cookie, err := r.Cookie("session"); err == nil {
+			*got = cookie.Value
+		}
+		writeJSON(w, http.StatusOK, `{}`)
+	}
+}
+
+func TestSubClientCookieOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		parentCookies bool
+		childCookies  bool
+		want          string
+	}{
+		{name: "enabled on the sub-client", parentCookies: false, childCookies: true, want: "abc"},
+		{name: "disabled on the sub-client", parentCookies: true, childCookies: false, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			parent := newTestClient(t, cookieHandler(&got), WithUseCookies(tt.parentCookies))
+			c := NewClient(WithParentClient(parent), WithUseCookies(tt.childCookies))
+
+			ctx := context.Background()
+			if err := c.Do(ctx, testRequest{method: http.MethodPost, path: "/login"}, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := c.Do(ctx, testRequest{method: http.MethodGet, path: "/me"}, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected session cookie %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// run with -race, both clients share the same http client
+func TestConcurrentClientsWithDifferentCookieSettings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+		} else if _, err := r.Cookie("session"); err == nil {
+			w.Header().Set("X-Has-Session", "true")
+		}
+		writeJSON(w, http.StatusOK, `{}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	shared := &http.Client{}
+	withCookies := NewClient(WithBaseURLString(srv.URL), WithHttpClient(shared), WithUseCookies(true))
+	withoutCookies := NewClient(WithBaseURLString(srv.URL), WithHttpClient(shared), WithUseCookies(false))
+
+	run := func(c Client, want string) error {
+		ctx := context.Background()
+		if err := c.Do(ctx, testRequest{method: http.MethodPost, path: "/login"}, nil); err != nil {
+			return err
+		}
+		for range 20 {
+			req := &headerRequest{testRequest: testRequest{method: http.MethodGet, path: "/me"}}
+			if err := c.Do(ctx, req, nil); err != nil {
+				return err
+			}
+			if got := req.header.Get("X-Has-Session"); got != want {
+				return fmt.Errorf("expected X-Has-Session %q, got %q", want, got)
+			}
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for _, tt := range []struct {
+		c    Client
+		want string
+	}{{withCookies, "true"}, {withoutCookies, ""}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- run(tt.c, tt.want)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if shared.Jar != nil {
+		t.Fatal("expected the shared http client to be left unmodified")
+	}
+}
+
+func TestCookiesAreSentAfterLogin(t *testing.T) {
+	var got string
+	c := newTestClient(t, cookieHandler(&got), WithUseCookies(true))
+
+	ctx := context.Background()
+	if err := c.Do(ctx, testRequest{method: http.MethodPost, path: "/login"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.Do(ctx, testRequest{method: http.MethodGet, path: "/me"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "abc" {
+		t.Fatalf("expected the session cookie to be sent, got %q", got)
+	}
+}
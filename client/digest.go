@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type (
+	digestAuth struct {
+		username string
+		password string
+
+		mu        sync.Mutex
+		challenge *digestChallenge
+		nc        uint32
+	}
+
+	digestChallenge struct {
+		realm     string
+		nonce     string
+		opaque    string
+		algorithm string
+		qop       string
+	}
+)
+
+// authorize sets the Authorization header when a challenge was received before, saving the extra round trip. Without
+// a challenge the request is sent again with credentials once the server answers with one, so a body that can't be
+// rewound with GetBody is buffered for it. With a challenge the body is streamed, when the server answers with a new
+// challenge anyway the 401 response is returned
+func (d *digestAuth) authorize(req *http.Request) error {
+	d.mu.Lock()
+	if d.challenge != nil {
+		defer d.mu.Unlock()
+		return d.setAuthorization(req)
+	}
+	d.mu.Unlock()
+
+	if req.GetBody == nil {
+		if _, err := readRequestBody(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleChallenge answers the digest challenge of a 401 response by sending the request again with credentials,
+// other responses are returned as is
+func (d *digestAuth) handleChallenge(ctx context.Context, req *http.Request, resp *http.Response, roundTrip RoundTripFunc) (*http.Response, error) {
+	if resp.StatusCode != http.StatusUnauthorized || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return resp, nil
+	}
+	challenge, ok := parseDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+	if !ok {
+		return resp, nil
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	retryReq := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to reset request body: %w", err)
+		}
+		retryReq.Body = body
+	}
+
+	d.mu.Lock()
+	d.challenge = challenge
+	d.nc = 0
+	err := d.setAuthorization(retryReq)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return roundTrip(ctx, retryReq)
+}
+
+// setAuthorization computes the digest response for the current challenge, the caller must hold the lock
+func (d *digestAuth) setAuthorization(req *http.Request) error {
+	ch := d.challenge
+
+	var h func() hash.Hash
+	algorithm := strings.ToUpper(ch.algorithm)
+	switch strings.TrimSuffix(algorithm, "-SESS") {
+	case "", "MD5":
+		h = md5.New
+	case "SHA-256":
+		h = sha256.New
+	default:
+		return fmt.Errorf("unsupported digest algorithm %s", ch.algorithm)
+	}
+	hashHex := func(parts ...string) string {
+		hh := h()
+		hh.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	cnonceBytes := make([]byte, 16)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return fmt.Errorf("failed to generate digest cnonce: %w", err)
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+
+	d.nc++
+	nc := fmt.Sprintf("%08x", d.nc)
+	uri := req.URL.RequestURI()
+
+	ha1 := hashHex(d.username, ch.realm, d.password)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = hashHex(ha1, ch.nonce, cnonce)
+	}
+	ha2 := hashHex(req.Method, uri)
+
+	params := []string{
+		fmt.Sprintf(`username="%s"`, escapeQuotes(d.username)),
+		fmt.Sprintf(`realm="%s"`, escapeQuotes(ch.realm)),
+		fmt.Sprintf(`nonce="%s"`, escapeQuotes(ch.nonce)),
+		fmt.Sprintf(`uri="%s"`, escapeQuotes(uri)),
+	}
+	if ch.algorithm != "" {
+		params = append(params, "algorithm="+ch.algorithm)
+	}
+	if ch.qop != "" {
+		params = append(params,
+			"qop="+ch.qop,
+			"nc="+nc,
+			fmt.Sprintf(`cnonce="%s"`, cnonce),
+			fmt.Sprintf(`response="%s"`, hashHex(ha1, ch.nonce, nc, cnonce, ch.qop, ha2)),
+		)
+	} else {
+		params = append(params, fmt.Sprintf(`response="%s"`, hashHex(ha1, ch.nonce, ha2)))
+	}
+	if ch.opaque != "" {
+		params = append(params, fmt.Sprintf(`opaque="%s"`, escapeQuotes(ch.opaque)))
+	}
+
+	req.Header.Set("Authorization", "Digest "+strings.Join(params, ", "))
+	return nil
+}
+
+// parseDigestChallenge finds the Digest challenge in the WWW-Authenticate headers. Only the auth quality of
+// protection is supported, challenges offering just auth-int are answered without qop
+func parseDigestChallenge(headers []string) (*digestChallenge, bool) {
+	for _, header := range headers {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+
+		params := parseAuthParams(rest)
+		ch := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+		}
+		if ch.nonce == "" {
+			return nil, false
+		}
+		qops := strings.Split(params["qop"], ",")
+		for i := range qops {
+			qops[i] = strings.TrimSpace(qops[i])
+		}
+		if slices.Contains(qops, "auth") {
+			ch.qop = "auth"
+		}
+		return ch, true
+	}
+	return nil, false
+}
+
+// parseAuthParams parses comma separated key=value pairs, where values may be quoted strings containing commas
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for s != "" {
+		s = strings.TrimLeft(s, " ,")
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " ")
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				b.WriteByte(rest[i])
+			}
+			value = b.String()
+			if i < len(rest) {
+				i++
+			}
+			s = rest[i:]
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+		params[key] = value
+	}
+	return params
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDigestAuthOnlyBuffersBodiesBeforeAChallenge(t *testing.T) {
+	type received struct {
+		body          string
+		contentLength int64
+	}
+	var requests []received
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Digest ") {
+			w.Header().Set("WWW-Authenticate", `Digest realm="test", nonce="abc", qop="auth"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		requests = append(requests, received{body: string(b), contentLength: r.ContentLength})
+		writeJSON(w, http.StatusOK, `{}`)
+	}, WithDigestAuth("user", "pass"))
+
+	// io.MultiReader hides the type of the reader, so net/http can't rewind or size it
+	streamed := func() Request {
+		return testBodyRequest{testRequest{method: http.MethodPost, path: "/"}, io.MultiReader(strings.NewReader("payload"))}
+	}
+	ctx := context.Background()
+	for range 2 {
+		if err := c.Do(ctx, streamed(), nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(requests) != 2 || requests[0].body != "payload" || requests[1].body != "payload" {
+		t.Fatalf("expected both bodies to be received, got %+v", requests)
+	}
+	if requests[0].contentLength != int64(len("payload")) {
+		t.Fatalf("expected the body to be buffered before the challenge, got length %d", requests[0].contentLength)
+	}
+	if requests[1].contentLength != -1 {
+		t.Fatalf("expected the body to be streamed once the challenge is known, got length %d", requests[1].contentLength)
+	}
+}
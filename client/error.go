@@ -1,14 +1,35 @@
 package client
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"strings"
 )
 
+const defaultMaxErrorBodyBytes = 64 << 10
+
+var (
+	ErrResponseTooLarge = errors.New("response body too large")
+	ErrCircuitOpen      = errors.New("circuit breaker is open")
+	ErrTimeout          = errors.New("timeout")
+	// ErrAuthFailure is wrapped by errors getting credentials, like fetching an OAuth2 token
+	ErrAuthFailure = errors.New("authentication failure")
+	// ErrNotModified is returned for 304 responses, the response value is left untouched
+	ErrNotModified = errors.New("not modified")
+)
+
+// errAttemptTimeout is the cause of the context of an attempt that ran into WithPerAttemptTimeout, net/http returns
+// it instead of the context error so it wraps context.DeadlineExceeded to match ErrTimeout
+var errAttemptTimeout = fmt.Errorf("attempt timed out: %w", context.DeadlineExceeded)
+
 type (
 	ErrorResponse struct {
 		message  string
 		response *http.Response
+		body     []byte
 		Parent   error
 	}
 )
@@ -30,6 +51,11 @@ func (e ErrorResponse) Response() *http.Response {
 	return e.response
 }
 
+// Body returns the (possibly truncated) body of the response, if it was captured
+func (e ErrorResponse) Body() []byte {
+	return e.body
+}
+
 func NewErrorResponse(message string, response *http.Response, parent error) ErrorResponse {
 	return ErrorResponse{
 		message:  message,
@@ -37,3 +63,40 @@ func NewErrorResponse(message string, response *http.Response, parent error) Err
 		Parent:   parent,
 	}
 }
+
+// IsNotFound reports whether err wraps an ErrorResponse with status 404
+func IsNotFound(err error) bool {
+	return hasStatus(err, func(code int) bool { return code == http.StatusNotFound })
+}
+
+// IsUnauthorized reports whether err wraps an ErrorResponse with status 401
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, func(code int) bool { return code == http.StatusUnauthorized })
+}
+
+// IsRateLimited reports whether err wraps an ErrorResponse with status 429
+func IsRateLimited(err error) bool {
+	return hasStatus(err, func(code int) bool { return code == http.StatusTooManyRequests })
+}
+
+// IsServerError reports whether err wraps an ErrorResponse with a 5xx status
+func IsServerError(err error) bool {
+	return hasStatus(err, func(code int) bool { return code >= 500 && code <= 599 })
+}
+
+func hasStatus(err error, match func(code int) bool) bool {
+	var errResponse ErrorResponse
+	if !errors.As(err, &errResponse) || errResponse.response == nil {
+		return false
+	}
+	return match(errResponse.response.StatusCode)
+}
+
+// wrapTimeout makes deadline and network timeout errors match ErrTimeout
+func wrapTimeout(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
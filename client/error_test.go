@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type apiError struct {
+	Message string `json:"message"`
+	Code    string `json:"code"`
+}
+
+func (e *apiError) Error() string {
+	if e.Message == "" {
+		return ""
+	}
+	return e.Code + ": " + e.Message
+}
+
+type errorStructRequest struct {
+	testRequest
+	apiErr *apiError
+}
+
+func (r errorStructRequest) ErrorStructs() []error {
+	return []error{r.apiErr}
+}
+
+func TestErrorStructsAreNotDecodedFromSuccessResponses(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, `{"message":"created","code":"ok"}`)
+	})
+
+	req := errorStructRequest{testRequest: testRequest{method: http.MethodGet, path: "/"}, apiErr: &apiError{}}
+	var resp struct {
+		Message string `json:"message"`
+	}
+	if err := c.Do(context.Background(), req, &resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Message != "created" {
+		t.Fatalf("expected the response to be decoded, got %q", resp.Message)
+	}
+	if req.apiErr.Message != "" {
+		t.Fatalf("expected the error struct to be left empty, got %+v", req.apiErr)
+	}
+}
+
+func TestErrorStructIsPopulatedFromErrorResponse(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, `{"message":"name is required","code":"invalid"}`)
+	})
+
+	req := errorStructRequest{testRequest: testRequest{method: http.MethodGet, path: "/"}, apiErr: &apiError{}}
+	err := c.Do(context.Background(), req, nil)
+
+	var got *apiError
+	if !errors.As(err, &got) {
+		t.Fatalf("expected an *apiError, got %v", err)
+	}
+	if got.Code != "invalid" || got.Message != "name is required" {
+		t.Fatalf("expected the error struct to be populated, got %+v", got)
+	}
+	var errResponse ErrorResponse
+	if !errors.As(err, &errResponse) || errResponse.Response().StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an ErrorResponse with status 400, got %v", err)
+	}
+}
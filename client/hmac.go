@@ -0,0 +1,89 @@
+package client
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type HMACConfig struct {
+	Secret []byte
+	// SignatureHeader is the header the hex encoded signature is sent in, defaults to X-Signature
+	SignatureHeader string
+	// TimestampHeader is the header the unix timestamp of the request is sent in, defaults to X-Timestamp
+	TimestampHeader string
+	// Canonicalize builds the message that is signed, defaults to DefaultHMACCanonicalization
+	Canonicalize func(req *http.Request, timestamp string, body []byte) string
+}
+
+// DefaultHMACCanonicalization signs the method, path including query, timestamp and body separated by newlines
+func DefaultHMACCanonicalization(req *http.Request, timestamp string, body []byte) string {
+	return strings.Join([]string{req.Method, req.URL.RequestURI(), timestamp, string(body)}, "\n")
+}
+
+func (c *client) signHMAC(req *http.Request) error {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return err
+	}
+
+	signatureHeader := c.hmacConfig.SignatureHeader
+	if signatureHeader == "" {
+		signatureHeader = "X-Signature"
+	}
+	timestampHeader := c.hmacConfig.TimestampHeader
+	if timestampHeader == "" {
+		timestampHeader = "X-Timestamp"
+	}
+	canonicalize := c.hmacConfig.Canonicalize
+	if canonicalize == nil {
+		canonicalize = DefaultHMACCanonicalization
+	}
+
+	timestamp := strconv.FormatInt(c.getClock().Now().Unix(), 10)
+	mac := hmac.New(sha256.New, c.hmacConfig.Secret)
+	mac.Write([]byte(canonicalize(req, timestamp, body)))
+
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(signatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+// readRequestBody reads the full request body and replaces it with a buffered copy, so the request can still be sent
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	return body, nil
+}
+
+// resetRequestBody rewinds a body buffered by readRequestBody, in case it was read
+func resetRequestBody(req *http.Request) error {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Errorf("failed to reset request body: %w", err)
+	}
+	req.Body = body
+	return nil
+}
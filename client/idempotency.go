@@ -0,0 +1,56 @@
+package client
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const defaultIdempotencyKeyHeader = "Idempotency-Key"
+
+// newIdempotencyKey returns a random (version 4) UUID
+func newIdempotencyKey() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate idempotency key: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// idempotencyKey returns a new key when the request opted in, the same key is sent on every attempt
+func (c *client) idempotencyKey(request Request) (string, error) {
+	reqWithIdempotency, ok := request.(RequestWithIdempotency)
+	if !ok || !reqWithIdempotency.UseIdempotencyKey() {
+		return "", nil
+	}
+	return newIdempotencyKey()
+}
+
+func (c *client) getIdempotencyKeyHeader() string {
+	if c.idempotencyKeyHeader == "" {
+		return defaultIdempotencyKeyHeader
+	}
+	return c.idempotencyKeyHeader
+}
+
+// canRetry reports whether the request may be sent again. Requests with an idempotent method (GET, HEAD, OPTIONS,
+// TRACE, PUT and DELETE) are, other methods like POST and PATCH only when they carry an idempotency key, so a retry
+// can't duplicate side effects. RequestWithIdempotent overrides this
+func (c *client) canRetry(request Request, idempotencyKey string) bool {
+	if reqWithIdempotent, ok := request.(RequestWithIdempotent); ok {
+		return reqWithIdempotent.Idempotent()
+	}
+
+	switch strings.ToUpper(request.Method()) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	if idempotencyKey != "" {
+		return true
+	}
+	reqWithHeaders, ok := request.(RequestWithHeaders)
+	return ok && reqWithHeaders.Headers().Get(c.getIdempotencyKeyHeader()) != ""
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/oauth2"
+	"net/http"
+	"testing"
+)
+
+type idempotentRequest struct {
+	testRequest
+}
+
+func (idempotentRequest) UseIdempotencyKey() bool {
+	return true
+}
+
+func TestRetriesSendTheSameIdempotencyKey(t *testing.T) {
+	var keys []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, http.StatusOK, `{}`)
+	}, WithMaxRetries(1), withoutBackoff())
+
+	req := idempotentRequest{testRequest{method: http.MethodPost, path: "/payments"}}
+	if err := c.Do(context.Background(), req, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Fatalf("expected the same key on both attempts, got %q", keys)
+	}
+}
+
+type optInRequest struct {
+	testRequest
+}
+
+func (optInRequest) Idempotent() bool {
+	return true
+}
+
+func TestPostIsOnlyRetriedWhenOptedIn(t *testing.T) {
+	tests := []struct {
+		name    string
+		request Request
+		want    int
+	}{
+		{name: "post without opt-in", request: testRequest{method: http.MethodPost, path: "/"}, want: 1},
+		{name: "post with idempotency key", request: idempotentRequest{testRequest{method: http.MethodPost, path: "/"}}, want: 3},
+		{name: "post marked idempotent", request: optInRequest{testRequest{method: http.MethodPost, path: "/"}}, want: 3},
+		{name: "put", request: testRequest{method: http.MethodPut, path: "/"}, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}, WithMaxRetries(2), withoutBackoff())
+
+			if err := c.Do(context.Background(), tt.request, nil); !IsServerError(err) {
+				t.Fatalf("expected the 503 error, got %v", err)
+			}
+			if attempts != tt.want {
+				t.Fatalf("expected %d attempts, got %d", tt.want, attempts)
+			}
+		})
+	}
+}
+
+// flakyTokenSource fails to return a token on the first call
+type flakyTokenSource struct {
+	calls int
+}
+
+func (s *flakyTokenSource) Token() (*oauth2.Token, error) {
+	s.calls++
+	if s.calls == 1 {
+		return nil, errors.New("token endpoint unavailable")
+	}
+	return &oauth2.Token{AccessToken: "token"}, nil
+}
+
+func TestPostIsRetriedWhenTheTokenFetchFailed(t *testing.T) {
+	attempts := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		writeJSON(w, http.StatusOK, `{}`)
+	}, WithOAuth2TokenSource(&flakyTokenSource{}), WithMaxRetries(1), withoutBackoff())
+
+	if err := c.Do(context.Background(), testRequest{method: http.MethodPost, path: "/"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected 1 request, got %d", attempts)
+	}
+}
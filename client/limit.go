@@ -0,0 +1,68 @@
+package client
+
+import (
+	"fmt"
+	"io"
+)
+
+// limitedBody errors once more than limit bytes are read, unlike io.LimitReader which silently truncates
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func newLimitedBody(body io.ReadCloser, limit int64) *limitedBody {
+	return &limitedBody{
+		ReadCloser: body,
+		limit:      limit,
+		remaining:  limit,
+	}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, b.tooLarge()
+	}
+
+	// read one byte more than allowed, so a body of exactly the limit isn't reported as too large
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = -1
+		return n, b.tooLarge()
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) tooLarge() error {
+	return fmt.Errorf("%w: limit is %d bytes", ErrResponseTooLarge, b.limit)
+}
+
+// countingBody counts the bytes read from the body
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// maxDrainBytes is how much of an unread body is discarded to reuse the connection, larger bodies are just closed
+const maxDrainBytes = 64 << 10
+
+// drainAndClose discards what is left of the body and closes it, so the connection can be reused
+func drainAndClose(body io.ReadCloser) {
+	if body == nil {
+		return
+	}
+	_, _ = io.CopyN(io.Discard, body, maxDrainBytes)
+	_ = body.Close()
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// trackedBody records whether it was read to the end and closed
+type trackedBody struct {
+	io.Reader
+	eof    bool
+	closed bool
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestResponseBodyIsDrainedAndClosed(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		wantErr     bool
+	}{
+		{name: "success", status: http.StatusOK, contentType: "application/json", body: `{"a":1}`},
+		{name: "error response", status: http.StatusInternalServerError, contentType: "application/json", body: `{"message":"failed"}`, wantErr: true},
+		{name: "unexpected content type", status: http.StatusOK, contentType: "text/html", body: `<html></html>`, wantErr: true},
+		{name: "not modified", status: http.StatusNotModified, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &trackedBody{Reader: strings.NewReader(tt.body)}
+			c := NewClient(WithBaseURLString("http://example.com"), WithTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: tt.status,
+					Status:     http.StatusText(tt.status),
+					Header:     http.Header{"Content-Type": {tt.contentType}},
+					Body:       body,
+					Request:    req,
+				}, nil
+			})))
+
+			var resp map[string]any
+			err := c.Do(context.Background(), testRequest{method: http.MethodGet, path: "/"}, &resp)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !body.closed {
+				t.Fatal("expected the response body to be closed")
+			}
+			if !body.eof {
+				t.Fatal("expected the response body to be drained")
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"slices"
+)
+
+// debugEnabled reports whether debug output (like request and response dumps) should be generated
+func (c *client) debugEnabled(ctx context.Context) bool {
+	if c.logger != nil {
+		return c.logger.Enabled(ctx, slog.LevelDebug)
+	}
+	return c.debug
+}
+
+// log writes a structured record to the configured logger. Without a logger the fallback message is written to the
+// standard logger instead, but only when debug is enabled
+func (c *client) log(ctx context.Context, level slog.Level, msg string, fallback string, attrs ...slog.Attr) {
+	if c.logger != nil {
+		c.logger.LogAttrs(ctx, level, msg, attrs...)
+		return
+	}
+	if c.debug {
+		log.Println(fallback)
+	}
+}
+
+const redactedValue = "***"
+
+var defaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization"}
+
+// dumpRequest dumps the outgoing request with sensitive headers redacted, the headers of the request itself are left
+// untouched
+func (c *client) dumpRequest(req *http.Request) []byte {
+	header := req.Header
+	req.Header = c.redactHeaders(header)
+	defer func() {
+		req.Header = header
+	}()
+
+	dump, _ := httputil.DumpRequestOut(req, true)
+	return dump
+}
+
+// redactHeaders returns a copy of the headers with the values of sensitive headers replaced
+func (c *client) redactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	keys := slices.Concat(defaultRedactedHeaders, c.redactedHeaders)
+	// the API key of a parent client is sent by sub-clients without their own authentication
+	if keyHeader := c.authClient().keyHeader; keyHeader != "" {
+		keys = append(keys, keyHeader)
+	}
+	for _, k := range keys {
+		if vv := redacted.Values(k); len(vv) > 0 {
+			redacted.Del(k)
+			for range vv {
+				redacted.Add(k, redactedValue)
+			}
+		}
+	}
+	return redacted
+}
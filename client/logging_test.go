@@ -0,0 +1,35 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDebugLogRedactsParentApiKey(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	var received string
+	parent := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("X-Api-Key")
+		writeJSON(w, http.StatusOK, `{}`)
+	}, WithApiKeyAuth("X-Api-Key", "secret-key"))
+	c := NewClient(WithParentClient(parent), WithDebug(true), WithLogger(logger))
+
+	if err := c.Do(context.Background(), testRequest{method: http.MethodGet, path: "/"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received != "secret-key" {
+		t.Fatalf("expected the parent's api key to be sent, got %q", received)
+	}
+	if !strings.Contains(logs.String(), "X-Api-Key") {
+		t.Fatalf("expected the request to be logged, got %q", logs.String())
+	}
+	if strings.Contains(logs.String(), "secret-key") {
+		t.Fatalf("expected the api key to be redacted, got %q", logs.String())
+	}
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"strconv"
+	"time"
+)
+
+type clientMetrics struct {
+	requests metric.Int64Counter
+	retries  metric.Int64Counter
+	duration metric.Float64Histogram
+	inFlight metric.Int64UpDownCounter
+}
+
+func newClientMetrics(provider metric.MeterProvider, name string) *clientMetrics {
+	meter := provider.Meter(name)
+
+	var m clientMetrics
+	var err, errs error
+	m.requests, err = meter.Int64Counter("http.client.requests",
+		metric.WithDescription("Number of http requests sent, by method and status class"))
+	errs = errors.Join(errs, err)
+	m.retries, err = meter.Int64Counter("http.client.retries",
+		metric.WithDescription("Number of retried http requests"))
+	errs = errors.Join(errs, err)
+	m.duration, err = meter.Float64Histogram("http.client.request.duration",
+		metric.WithDescription("Duration of http requests"),
+		metric.WithUnit("s"))
+	errs = errors.Join(errs, err)
+	m.inFlight, err = meter.Int64UpDownCounter("http.client.active_requests",
+		metric.WithDescription("Number of http requests in flight"))
+	errs = errors.Join(errs, err)
+
+	if errs != nil {
+		otel.Handle(errs)
+	}
+	return &m
+}
+
+// start records an http call as in flight, the returned function records its outcome once it is done
+func (m *clientMetrics) start(ctx context.Context, method string) func(statusCode int, err error) {
+	methodAttr := attribute.String("http.method", method)
+	m.inFlight.Add(ctx, 1, metric.WithAttributes(methodAttr))
+	start := time.Now()
+
+	return func(statusCode int, err error) {
+		m.inFlight.Add(ctx, -1, metric.WithAttributes(methodAttr))
+
+		attrs := metric.WithAttributes(methodAttr, attribute.String("http.status_class", statusClass(statusCode, err)))
+		m.requests.Add(ctx, 1, attrs)
+		m.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+	}
+}
+
+func (m *clientMetrics) retry(ctx context.Context, method string) {
+	m.retries.Add(ctx, 1, metric.WithAttributes(attribute.String("http.method", method)))
+}
+
+func statusClass(statusCode int, err error) string {
+	if err != nil {
+		return "error"
+	}
+	return strconv.Itoa(statusCode/100) + "xx"
+}
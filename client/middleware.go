@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+type (
+	// RoundTripFunc sends a fully built request and returns its response
+	RoundTripFunc func(ctx context.Context, req *http.Request) (*http.Response, error)
+
+	// Middleware wraps the round trip of every attempt, it can modify the request or response or short-circuit the
+	// call by not calling next
+	Middleware func(next RoundTripFunc) RoundTripFunc
+)
+
+// roundTrip sends the request through the middleware chain, the first registered middleware being the outermost
+func (c *client) roundTrip(ctx context.Context, req *http.Request) (*http.Response, error) {
+	next := RoundTripFunc(func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return c.getHttpClient().Do(req.WithContext(ctx))
+	})
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		next = c.middleware[i](next)
+	}
+	return next(ctx, req)
+}
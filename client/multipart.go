@@ -0,0 +1,135 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/textproto"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type (
+	// MultipartBody can be returned from RequestWithBody.Body to send a multipart/form-data request. Files are streamed
+	// from their readers, readers implementing io.Closer are closed once the request is done with them. The request is
+	// only retried when every reader can be rewound, it implements io.Seeker and not io.Closer
+	MultipartBody struct {
+		Fields map[string]string
+		Files  []MultipartFile
+	}
+
+	MultipartFile struct {
+		FieldName   string
+		FileName    string
+		ContentType string // defaults to application/octet-stream
+		Reader      io.Reader
+	}
+
+	multipartReader struct {
+		body   MultipartBody
+		pr     *io.PipeReader
+		pw     *io.PipeWriter
+		writer *multipart.Writer
+		once   sync.Once
+	}
+)
+
+var _ io.ReadCloser = (*multipartReader)(nil)
+
+func newMultipartReader(body MultipartBody) *multipartReader {
+	pr, pw := io.Pipe()
+	return &multipartReader{
+		body:   body,
+		pr:     pr,
+		pw:     pw,
+		writer: multipart.NewWriter(pw),
+	}
+}
+
+func (r *multipartReader) ContentType() string {
+	return r.writer.FormDataContentType()
+}
+
+// Read starts encoding the body on the first call, so nothing is written when the request is never sent
+func (r *multipartReader) Read(p []byte) (int, error) {
+	r.once.Do(func() {
+		go r.write()
+	})
+	return r.pr.Read(p)
+}
+
+func (r *multipartReader) Close() error {
+	err := r.pr.Close()
+	// when the body was never read the files still need closing, otherwise the writer goroutine closes them
+	r.once.Do(r.closeFiles)
+	return err
+}
+
+func (r *multipartReader) write() {
+	defer r.closeFiles()
+	r.pw.CloseWithError(r.writeParts())
+}
+
+func (r *multipartReader) writeParts() error {
+	for _, k := range slices.Sorted(maps.Keys(r.body.Fields)) {
+		if err := r.writer.WriteField(k, r.body.Fields[k]); err != nil {
+			return fmt.Errorf("failed to write multipart field %s: %w", k, err)
+		}
+	}
+
+	for _, f := range r.body.Files {
+		contentType := f.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			escapeQuotes(f.FieldName), escapeQuotes(f.FileName)))
+		h.Set("Content-Type", contentType)
+
+		part, err := r.writer.CreatePart(h)
+		if err != nil {
+			return fmt.Errorf("failed to create multipart file %s: %w", f.FieldName, err)
+		}
+		if _, err := io.Copy(part, f.Reader); err != nil {
+			return fmt.Errorf("failed to write multipart file %s: %w", f.FieldName, err)
+		}
+	}
+
+	return r.writer.Close()
+}
+
+func (r *multipartReader) closeFiles() {
+	for _, f := range r.body.Files {
+		if closer, ok := f.Reader.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+}
+
+func (b MultipartBody) canRewind() bool {
+	for _, f := range b.Files {
+		if !canRewindReader(f.Reader) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b MultipartBody) rewind() error {
+	for _, f := range b.Files {
+		if err := rewindReader(f.Reader); err != nil {
+			return fmt.Errorf("multipart file %s: %w", f.FieldName, err)
+		}
+	}
+	return nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMultipartBodyIsRewoundForRetries(t *testing.T) {
+	var files []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("failed to read file part: %v", err)
+			return
+		}
+		b, _ := io.ReadAll(file)
+		files = append(files, string(b))
+		if len(files) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, http.StatusOK, `{}`)
+	}, WithMaxRetries(1), withoutBackoff())
+
+	body := MultipartBody{Files: []MultipartFile{{FieldName: "file", FileName: "a.txt", Reader: strings.NewReader("content")}}}
+	err := c.Do(context.Background(), testBodyRequest{testRequest{method: http.MethodPut, path: "/upload"}, body}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 2 || files[0] != "content" || files[1] != "content" {
+		t.Fatalf("expected the file on both attempts, got %q", files)
+	}
+}
+
+func TestMultipartBodyThatCantBeRewoundIsNotRetried(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("content"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attempts := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithMaxRetries(1), withoutBackoff())
+
+	body := MultipartBody{Files: []MultipartFile{{FieldName: "file", FileName: "a.txt", Reader: f}}}
+	err = c.Do(context.Background(), testBodyRequest{testRequest{method: http.MethodPut, path: "/upload"}, body}, nil)
+	if !IsServerError(err) {
+		t.Fatalf("expected the 503 error, got %v", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestReaderBodyIsRewoundForRetries(t *testing.T) {
+	var bodies []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, http.StatusOK, `{}`)
+	}, WithMaxRetries(1), withoutBackoff())
+
+	req := testBodyRequest{testRequest{method: http.MethodPut, path: "/"}, strings.NewReader("payload")}
+	if err := c.Do(context.Background(), req, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bodies) != 2 || bodies[1] != "payload" {
+		t.Fatalf("expected the body on both attempts, got %q", bodies)
+	}
+}
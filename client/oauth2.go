@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenCache holds the token of one or more clients, lock is a channel so waiting for it can be canceled
+type tokenCache struct {
+	lock  chan struct{}
+	token *oauth2.Token
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{
+		lock: make(chan struct{}, 1),
+	}
+}
+
+// sharedTokenCaches holds the token caches of client credentials configs, so clients with the same config share a
+// token instead of fetching one each
+var sharedTokenCaches sync.Map
+
+func sharedTokenCache(config clientcredentials.Config) *tokenCache {
+	scopes := slices.Clone(config.Scopes)
+	slices.Sort(scopes)
+	secret := sha256.Sum256([]byte(config.ClientSecret))
+	key := strings.Join([]string{
+		config.TokenURL,
+		config.ClientID,
+		hex.EncodeToString(secret[:]),
+		strings.Join(scopes, " "),
+		strconv.Itoa(int(config.AuthStyle)),
+		config.EndpointParams.Encode(),
+	}, "\n")
+
+	cache, _ := sharedTokenCaches.LoadOrStore(key, newTokenCache())
+	return cache.(*tokenCache)
+}
+
+// oauth2Token returns the cached token, or fetches a new one using the request context when it is no longer valid.
+// Only one fetch runs at a time, also for clients sharing the cache, callers waiting for it give up when their context
+// is done
+func (c *client) oauth2Token(ctx context.Context) (*oauth2.Token, error) {
+	select {
+	case c.tokens.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() {
+		<-c.tokens.lock
+	}()
+
+	if c.tokenValid() {
+		return c.tokens.token, nil
+	}
+
+	if c.baseClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, c.baseClient)
+	}
+	ctx, span := c.tracer().Start(ctx, "oauth2 token")
+	defer span.End()
+
+	token, err := c.fetchToken(ctx)
+	if err != nil {
+		span.RecordError(err, trace.WithStackTrace(true))
+		return nil, err
+	}
+	c.tokens.token = token
+	return token, nil
+}
+
+// tokenValid is oauth2.Token.Valid using the clock of the client, tokens are renewed 10 seconds before they expire
+func (c *client) tokenValid() bool {
+	token := c.tokens.token
+	if token == nil || token.AccessToken == "" {
+		return false
+	}
+	return token.Expiry.IsZero() || c.getClock().Now().Add(10*time.Second).Before(token.Expiry)
+}
+
+// isRetryableTokenError reports whether fetching the token can succeed when tried again, errors responses from the token
+// endpoint other than 5xx and 429 (like invalid credentials) are not
+func isRetryableTokenError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.Response != nil {
+		code := retrieveErr.Response.StatusCode
+		return code >= 500 || code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+func (c *client) fetchToken(ctx context.Context) (*oauth2.Token, error) {
+	if c.oauth2Config != nil {
+		return c.oauth2Config.Token(ctx)
+	}
+	if c.tokenSource == nil {
+		return nil, errors.New("no oauth2 token source configured")
+	}
+
+	// a plain token source has no context, so we stop waiting for it when the context is done
+	type result struct {
+		token *oauth2.Token
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		token, err := c.tokenSource.Token()
+		done <- result{token, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.token, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
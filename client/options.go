@@ -2,22 +2,147 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
+	"time"
 )
 
+// WithHttpClient sets the http client used for requests, it is also used to fetch OAuth2 tokens
 func WithHttpClient(httpClient *http.Client) Option {
 	return func(client *client) {
 		client.baseClient = httpClient
+		client.httpClient = httpClient
+		client.ownHttpClient = true
+	}
+}
 
-		// if we have oauth2 configured, wrap the given http client with the oauth2 client
-		if client.authType == authTypeOAuth2 {
-			client.httpClient = getWrappedHttpClient(client.baseClient, client.tokenSource)
-		} else {
-			client.httpClient = httpClient
-		}
+// WithTransport replaces the round tripper of the http client, the client set with WithHttpClient is copied instead of
+// modified
+func WithTransport(rt http.RoundTripper) Option {
+	return func(client *client) {
+		client.modifyHttpClient(func(httpClient *http.Client) {
+			httpClient.Transport = rt
+		})
+	}
+}
+
+// WithRedirectPolicy sets the CheckRedirect function of the http client, see http.Client
+func WithRedirectPolicy(policy func(req *http.Request, via []*http.Request) error) Option {
+	return func(client *client) {
+		client.modifyHttpClient(func(httpClient *http.Client) {
+			httpClient.CheckRedirect = policy
+		})
+	}
+}
+
+// WithoutRedirects doesn't follow redirects, Do returns them as an ErrorResponse whose Response has the Location header
+func WithoutRedirects() Option {
+	return WithRedirectPolicy(func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	})
+}
+
+// WithDialTimeout limits the time to establish a connection
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(client *client) {
+		client.modifyTransport("WithDialTimeout", func(transport *http.Transport) {
+			transport.DialContext = (&net.Dialer{
+				Timeout:   timeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext
+		})
+	}
+}
+
+// WithTLSHandshakeTimeout limits the time of the TLS handshake
+func WithTLSHandshakeTimeout(timeout time.Duration) Option {
+	return func(client *client) {
+		client.modifyTransport("WithTLSHandshakeTimeout", func(transport *http.Transport) {
+			transport.TLSHandshakeTimeout = timeout
+		})
+	}
+}
+
+// WithResponseHeaderTimeout limits the time waiting for the response headers after the request is written
+func WithResponseHeaderTimeout(timeout time.Duration) Option {
+	return func(client *client) {
+		client.modifyTransport("WithResponseHeaderTimeout", func(transport *http.Transport) {
+			transport.ResponseHeaderTimeout = timeout
+		})
+	}
+}
+
+// WithIdleConnTimeout limits how long idle connections are kept open
+func WithIdleConnTimeout(timeout time.Duration) Option {
+	return func(client *client) {
+		client.modifyTransport("WithIdleConnTimeout", func(transport *http.Transport) {
+			transport.IdleConnTimeout = timeout
+		})
+	}
+}
+
+// WithClientCertificate presents the certificate when the server requests one (mutual TLS), it is added to the TLS
+// config of the transport
+func WithClientCertificate(cert tls.Certificate) Option {
+	return func(client *client) {
+		client.modifyTLSConfig("WithClientCertificate", func(config *tls.Config) {
+			config.Certificates = append(config.Certificates, cert)
+		})
+	}
+}
+
+// WithRootCAs verifies server certificates against the pool instead of the system roots
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(client *client) {
+		client.modifyTLSConfig("WithRootCAs", func(config *tls.Config) {
+			config.RootCAs = pool
+		})
+	}
+}
+
+// WithInsecureSkipVerify disables verification of server certificates, for development against self-signed
+// certificates only. A warning is logged on the first request
+func WithInsecureSkipVerify(skip bool) Option {
+	return func(client *client) {
+		client.insecureSkipVerify = skip
+		client.modifyTLSConfig("WithInsecureSkipVerify", func(config *tls.Config) {
+			config.InsecureSkipVerify = skip
+		})
+	}
+}
+
+// WithProxy sends requests through the proxy instead of the one from the environment, credentials in the URL are used
+// for proxy authentication. A nil URL disables proxying
+func WithProxy(proxyURL *url.URL) Option {
+	return func(client *client) {
+		client.modifyTransport("WithProxy", func(transport *http.Transport) {
+			if proxyURL == nil {
+				transport.Proxy = nil
+				return
+			}
+			transport.Proxy = http.ProxyURL(proxyURL)
+		})
+	}
+}
+
+// WithProxyFunc selects the proxy per request, see http.Transport.Proxy
+func WithProxyFunc(proxy func(req *http.Request) (*url.URL, error)) Option {
+	return func(client *client) {
+		client.modifyTransport("WithProxyFunc", func(transport *http.Transport) {
+			transport.Proxy = proxy
+		})
 	}
 }
 
@@ -26,6 +151,8 @@ func WithBasicAuth(username, password string) Option {
 		client.authType = authTypeBasic
 		client.userName = username
 		client.password = password
+		// encoded once instead of by SetBasicAuth on every request
+		client.basicAuthHeader = "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
 	}
 }
 
@@ -37,29 +164,67 @@ func WithApiKeyAuth(header, apiKey string) Option {
 	}
 }
 
-func getWrappedHttpClient(baseClient *http.Client, source oauth2.TokenSource) *http.Client {
-	if baseClient == nil {
-		return oauth2.NewClient(context.Background(), source)
+func WithBearerToken(token string) Option {
+	return func(client *client) {
+		client.authType = authTypeBearer
+		client.bearerToken = token
+	}
+}
+
+// WithHMACAuth signs every request with HMAC-SHA256 over the canonicalized request, including its body
+func WithHMACAuth(config HMACConfig) Option {
+	return func(client *client) {
+		client.authType = authTypeHMAC
+		client.hmacConfig = config
+	}
+}
+
+// WithAWSSigV4 signs every request with AWS signature version 4 for the region and service (like execute-api), the
+// body is hashed as part of the signature
+func WithAWSSigV4(creds AWSCredentials, region, service string) Option {
+	return func(client *client) {
+		client.authType = authTypeAWSSigV4
+		client.sigV4 = &awsSigV4{
+			credentials: creds,
+			region:      region,
+			service:     service,
+		}
+	}
+}
+
+// WithDigestAuth sets the client to use HTTP digest authentication (RFC 7616). The first request is answered with a
+// challenge and sent again with credentials, later requests reuse the challenge until the server sends a new one
+func WithDigestAuth(username, password string) Option {
+	return func(client *client) {
+		client.authType = authTypeDigest
+		client.digestAuth = &digestAuth{
+			username: username,
+			password: password,
+		}
 	}
-	return oauth2.NewClient(
-		context.WithValue(context.Background(), oauth2.HTTPClient, baseClient),
-		source,
-	)
 }
 
+// WithOAuth2ClientCredentials authenticates requests with a token from the client credentials flow. Tokens are fetched
+// with the context of the request that needs them, so they respect its deadline and cancellation. Clients with the
+// same config share their token
 func WithOAuth2ClientCredentials(config clientcredentials.Config) Option {
 	return func(client *client) {
 		client.authType = authTypeOAuth2
-		client.tokenSource = config.TokenSource(context.Background())
-		client.httpClient = getWrappedHttpClient(client.baseClient, client.tokenSource)
+		client.oauth2Config = &config
+		client.tokenSource = nil
+		client.tokens = sharedTokenCache(config)
 	}
 }
 
+// WithOAuth2TokenSource authenticates requests with tokens from the given source. Since a token source has no context,
+// a request whose context is done stops waiting for the token, but the fetch itself continues. Clients can share a
+// source wrapped in oauth2.ReuseTokenSource to fetch tokens once
 func WithOAuth2TokenSource(source oauth2.TokenSource) Option {
 	return func(client *client) {
 		client.authType = authTypeOAuth2
 		client.tokenSource = source
-		client.httpClient = getWrappedHttpClient(client.baseClient, client.tokenSource)
+		client.oauth2Config = nil
+		client.tokens = newTokenCache()
 	}
 }
 
@@ -69,18 +234,113 @@ func WithDebug(debug bool) Option {
 	}
 }
 
+// WithLogger sets a structured logger for request/response dumps (debug level) and failed attempts (warn level). The
+// logger's level decides what is emitted, without a logger the standard logger is used when debug is enabled
+func WithLogger(logger *slog.Logger) Option {
+	return func(client *client) {
+		client.logger = logger
+	}
+}
+
+// WithRedactedHeaders adds headers whose values are replaced in debug dumps, next to Authorization,
+// Proxy-Authorization and the api key header which are always redacted
+func WithRedactedHeaders(keys ...string) Option {
+	return func(client *client) {
+		client.redactedHeaders = append(client.redactedHeaders, keys...)
+	}
+}
+
+// WithUserAgent sets the User-Agent header, an empty string sends no User-Agent at all
 func WithUserAgent(userAgent string) Option {
 	return func(client *client) {
 		client.userAgent = userAgent
 	}
 }
 
+// WithoutUserAgent sends requests without a User-Agent header
+func WithoutUserAgent() Option {
+	return WithUserAgent("")
+}
+
 func WithBaseURL(baseURL url.URL) Option {
 	return func(client *client) {
 		client.baseURL = &baseURL
 	}
 }
 
+// WithBaseURLString parses the base URL, a parse error is returned by every call to Do
+func WithBaseURLString(rawURL string) Option {
+	return func(client *client) {
+		baseURL, err := url.Parse(rawURL)
+		if err != nil {
+			client.optionErrs = append(client.optionErrs, fmt.Errorf("invalid base URL: %w", err))
+			return
+		}
+		client.baseURL = baseURL
+	}
+}
+
+// WithBasePath prefixes the path of every request, after the path of the base URL. Path templates are joined to it
+// whether they start with a slash or not. The prefix is used as is, so it should already be escaped
+func WithBasePath(prefix string) Option {
+	return func(client *client) {
+		client.basePath = prefix
+	}
+}
+
+// WithPreserveTemplateQuery keeps the query of the base URL and path template verbatim, in their order and with their
+// escaping, instead of decoding and encoding it again (which sorts the keys). Tagged query params are appended. This
+// is needed for APIs that sign the query as it is sent
+func WithPreserveTemplateQuery(preserve bool) Option {
+	return func(client *client) {
+		client.preserveTemplateQuery = preserve
+	}
+}
+
+// WithQueryEncoder replaces url.Values.Encode for encoding the query, for example to keep a parameter order required
+// by a request signature
+func WithQueryEncoder(encode func(url.Values) string) Option {
+	return func(client *client) {
+		client.queryEncoder = encode
+	}
+}
+
+// WithDefaultHeader adds a header that is sent with every request. Calling it again for the same key appends another
+// value, except for headers the client sets itself (Content-Type, Accept, User-Agent and Authorization), which are
+// replaced. Per-request headers from RequestWithHeaders take precedence over default headers
+func WithDefaultHeader(key, value string) Option {
+	return func(client *client) {
+		if client.defaultHeaders == nil {
+			client.defaultHeaders = http.Header{}
+		}
+		if isReservedHeader(key) {
+			client.defaultHeaders.Set(key, value)
+		} else {
+			client.defaultHeaders.Add(key, value)
+		}
+	}
+}
+
+// WithContextHeader sets the header from the string value stored under contextKey in the context passed to Do, like a
+// correlation ID. Nothing is set when the context has no string value for the key. Per-request headers from
+// RequestWithHeaders take precedence
+func WithContextHeader(contextKey any, headerName string) Option {
+	return func(client *client) {
+		client.contextHeaders = append(client.contextHeaders, contextHeader{contextKey: contextKey, header: headerName})
+	}
+}
+
+// WithDefaultHeaders adds all given headers as default headers, see WithDefaultHeader
+func WithDefaultHeaders(headers http.Header) Option {
+	return func(client *client) {
+		for k, vv := range headers {
+			for _, v := range vv {
+				WithDefaultHeader(k, v)(client)
+			}
+		}
+	}
+}
+
 func WithDisallowUnknownFields(disallowUnknownFields bool) Option {
 	return func(client *client) {
 		client.disallowUnknownFields = disallowUnknownFields
@@ -88,30 +348,334 @@ func WithDisallowUnknownFields(disallowUnknownFields bool) Option {
 	}
 }
 
+// WithJsoniterConfig replaces the jsoniter config used for encoding and decoding, WithDisallowUnknownFields still
+// applies on top of it
+func WithJsoniterConfig(config jsoniter.Config) Option {
+	return func(client *client) {
+		client.jsoniterConfig = &config
+		client.jsoniterInstance = nil
+		client.dynamicJsoniter = nil
+	}
+}
+
+// WithResponseUnwrapper strips an envelope from successful response bodies before they are decoded into the response,
+// error responses are decoded as is
+func WithResponseUnwrapper(unwrap func(raw []byte) ([]byte, error)) Option {
+	return func(client *client) {
+		client.responseUnwrapper = unwrap
+	}
+}
+
 func WithMediaType(mediaType string) Option {
 	return func(client *client) {
 		client.mediaType = mediaType
 	}
 }
 
+// WithAcceptVersion requests a version of a vendor media type in the Accept header, like application/vnd.acme.v3+json
+// for vendor "acme" and version "v3". The structured syntax (json) follows the media type, which is still used for the
+// Content-Type of request bodies
+func WithAcceptVersion(vendor, version string) Option {
+	return func(client *client) {
+		client.acceptVendor = vendor
+		client.acceptVersion = version
+	}
+}
+
+// WithCodec sets the codec used to encode request bodies and decode responses, defaults to JSON. The media type is set
+// to the codec's content type, use WithMediaType after this option to override it
+func WithCodec(codec Codec) Option {
+	return func(client *client) {
+		client.codec = codec
+		client.mediaType = codec.ContentType()
+	}
+}
+
 func WithCharset(charset string) Option {
 	return func(client *client) {
 		client.charset = charset
 	}
 }
 
+// WithAllowBodyOnGet sends the body of GET and HEAD requests implementing RequestWithBody, by default it is left out
+func WithAllowBodyOnGet(allow bool) Option {
+	return func(client *client) {
+		client.allowBodyOnGet = allow
+	}
+}
+
+// WithRequestCompression gzips request bodies that are encoded by the client (JSON, codec or form bodies), raw
+// readers, bytes and strings are sent as is
+func WithRequestCompression(enabled bool) Option {
+	return func(client *client) {
+		client.requestCompression = enabled
+	}
+}
+
+// WithRequestCompressionMinSize sets the minimum body size in bytes for request compression, defaults to 1024
+func WithRequestCompressionMinSize(n int) Option {
+	return func(client *client) {
+		client.compressionMinSize = n
+	}
+}
+
+// WithAutomaticDecompression advertises the encodings (gzip, deflate and br by default) in the Accept-Encoding header,
+// responses are decoded before they are read
+func WithAutomaticDecompression(encodings ...string) Option {
+	return func(client *client) {
+		if len(encodings) == 0 {
+			encodings = defaultAcceptEncodings
+		}
+		client.acceptEncodings = encodings
+	}
+}
+
+// WithMaxRetries sets how often a failed request is retried. Requests with a non-idempotent method, like POST and PATCH,
+// are only retried when they carry an idempotency key or opt in with RequestWithIdempotent
 func WithMaxRetries(maxRetries int) Option {
 	return func(client *client) {
 		client.maxRetries = maxRetries
 	}
 }
 
+// WithBackoff sets the strategy used to compute the delay between retries, defaults to a constant 100ms
+func WithBackoff(strategy BackoffStrategy) Option {
+	return func(client *client) {
+		client.backoff = strategy
+	}
+}
+
+// WithMaxRetryAfter sets the longest Retry-After delay that is waited for before retrying, defaults to 1 minute.
+// Responses asking to wait longer aren't retried
+func WithMaxRetryAfter(d time.Duration) Option {
+	return func(client *client) {
+		client.maxRetryAfter = d
+	}
+}
+
+// WithRetryableStatusCodes sets the response status codes that are retried (up to the configured max retries),
+// defaults to 429, 502, 503 and 504. Calling it without codes disables retrying on status codes
+func WithRetryableStatusCodes(codes ...int) Option {
+	return func(client *client) {
+		client.retryableStatus = append([]int{}, codes...)
+	}
+}
+
+// WithRetryPredicate replaces the status code based retry decision. The predicate is called with the response, its
+// body buffered so it can be read, or with the error when the request failed before a response was received or the
+// attempt timed out. Returning true retries the request (up to the configured max retries), false returns the result
+// as is
+func WithRetryPredicate(predicate func(resp *http.Response, err error) bool) Option {
+	return func(client *client) {
+		client.retryPredicate = predicate
+	}
+}
+
+// WithRetryBudget caps the retries of all requests sent by the client, so widespread failures don't multiply the load
+// on a recovering service. Over the last 10 seconds the retries may not exceed ratio times the number of requests,
+// plus minPerSec retries per second. Once the budget is exhausted failed requests return without retrying
+func WithRetryBudget(ratio float64, minPerSec int) Option {
+	return func(client *client) {
+		client.retryBudget = newRetryBudget(ratio, minPerSec)
+	}
+}
+
+// WithBodyTee passes the request and response body of every request to sink once the request is done, for auditing.
+// With retries the bodies of the last attempt are passed, respBody is nil when no response was read. Both bodies are
+// held in memory in full, streamed request bodies are buffered to copy them, so be careful with large payloads.
+// Streamed responses (RequestWithStreamHandler, Subscribe) aren't captured
+func WithBodyTee(sink func(ctx context.Context, reqBody, respBody []byte)) Option {
+	return func(client *client) {
+		client.bodyTee = sink
+	}
+}
+
+// WithMaxConcurrency limits how many requests of a DoBatch call run at the same time, defaults to 10
+func WithMaxConcurrency(n int) Option {
+	return func(client *client) {
+		client.maxConcurrency = n
+	}
+}
+
+// WithRateLimiter sets a limiter that is waited on before every attempt, including retries
+func WithRateLimiter(limiter RateLimiter) Option {
+	return func(client *client) {
+		client.rateLimiter = limiter
+	}
+}
+
+// WithMiddleware adds middleware around the http call of every attempt, the first given middleware is the outermost
+func WithMiddleware(mw ...Middleware) Option {
+	return func(client *client) {
+		client.middleware = append(client.middleware, mw...)
+	}
+}
+
+// WithRequestHook calls the hook with the final request of every attempt just before it is sent, the hook must not read
+// the body
+func WithRequestHook(hook func(*http.Request)) Option {
+	return func(client *client) {
+		client.requestHooks = append(client.requestHooks, hook)
+	}
+}
+
+// WithResponseHook calls the hook with the response of every attempt as soon as it is received, before the body is
+// decompressed or buffered. The hook must not read the body
+func WithResponseHook(hook func(*http.Response)) Option {
+	return func(client *client) {
+		client.responseHooks = append(client.responseHooks, hook)
+	}
+}
+
+// WithMaxResponseBytes limits the size of response bodies, larger bodies result in an error wrapping
+// ErrResponseTooLarge. Defaults to 0, which means unlimited
+func WithMaxResponseBytes(n int64) Option {
+	return func(client *client) {
+		client.maxResponseBytes = n
+	}
+}
+
+// WithMaxErrorBodyBytes limits how much of an error response body is kept in ErrorResponse, defaults to 64KiB. 0
+// keeps nothing
+func WithMaxErrorBodyBytes(n int64) Option {
+	return func(client *client) {
+		client.maxErrorBodyBytes = n
+	}
+}
+
+// WithCircuitBreaker guards every attempt with the given breaker, transport errors and 5xx responses count as failures
+func WithCircuitBreaker(cb CircuitBreaker) Option {
+	return func(client *client) {
+		client.circuitBreaker = cb
+	}
+}
+
+// WithCache caches successful GET responses according to their Cache-Control header, stale responses with an ETag
+// are revalidated with If-None-Match
+func WithCache(cache ResponseCache) Option {
+	return func(client *client) {
+		client.cache = cache
+	}
+}
+
+// WithTimeout limits the total duration of Do, including all retries. A context with an earlier deadline keeps it
+func WithTimeout(d time.Duration) Option {
+	return func(client *client) {
+		client.timeout = d
+	}
+}
+
+// WithPerAttemptTimeout limits the duration of every attempt, including reading the response, while WithTimeout and the
+// context limit Do as a whole. An attempt timing out is retried when retries are left and the retry predicate, if any,
+// accepts it
+func WithPerAttemptTimeout(timeout time.Duration) Option {
+	return func(client *client) {
+		client.perAttemptTimeout = timeout
+	}
+}
+
+// WithClock replaces the real time used for retry delays, caching, token expiry, HMAC timestamps and the circuit breaker
+func WithClock(clock Clock) Option {
+	return func(client *client) {
+		client.clock = clock
+	}
+}
+
+// WithPropagator sets the propagator used to inject the trace context into outgoing requests, defaults to the global
+// propagator
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(client *client) {
+		client.propagator = propagator
+	}
+}
+
+// WithTracerProvider sets the provider of the tracer used to create a span for every request, defaults to the global
+// provider
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(client *client) {
+		client.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider enables metrics for request counts, latency, requests in flight and retries
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(client *client) {
+		client.meterProvider = provider
+		client.metrics = newClientMetrics(provider, client.getName())
+	}
+}
+
+// WithName sets the tracer and meter name, so clients for different APIs can be told apart. Defaults to the package
+// path
+func WithName(name string) Option {
+	return func(client *client) {
+		client.name = name
+		if client.meterProvider != nil {
+			client.metrics = newClientMetrics(client.meterProvider, client.getName())
+		}
+	}
+}
+
+// WithSemanticConventions selects the OpenTelemetry semantic conventions version for the span attributes, like
+// "v1.26.0". From v1.23.0 on the stable names (http.request.method, url.full, server.address,
+// http.response.status_code, error.type) are used, by default the older names (http.method, http.url,
+// http.status_code) are kept
+func WithSemanticConventions(version string) Option {
+	return func(client *client) {
+		major, minor, err := parseSemconvVersion(version)
+		if err != nil {
+			client.optionErrs = append(client.optionErrs, err)
+			return
+		}
+		stableMajor, stableMinor, _ := parseSemconvVersion(stableSemconvVersion)
+		keys := legacySemconv
+		if major > stableMajor || (major == stableMajor && minor >= stableMinor) {
+			keys = stableSemconv
+		}
+		client.semconvKeys = &keys
+	}
+}
+
+// WithMethodOverrideHeader sends every request as a POST, with the method of the request in the given header (like
+// X-HTTP-Method-Override), for APIs behind proxies that only allow GET and POST
+func WithMethodOverrideHeader(header string) Option {
+	return func(client *client) {
+		client.methodOverrideHeader = header
+	}
+}
+
+// WithIdempotencyKeyHeader sets the header carrying the idempotency key of requests implementing
+// RequestWithIdempotency, defaults to Idempotency-Key
+func WithIdempotencyKeyHeader(header string) Option {
+	return func(client *client) {
+		client.idempotencyKeyHeader = header
+	}
+}
+
+// WithUseCookies keeps cookies set by responses and sends them on subsequent requests of the same client, using an
+// in-memory jar unless one is set with WithCookieJar
 func WithUseCookies(useCookies bool) Option {
 	return func(client *client) {
 		client.useCookies = useCookies
+		client.cookieOption = true
+	}
+}
+
+// WithCookieJar stores cookies in the given jar instead of an in-memory one and enables cookie handling. The jar is not
+// used when fetching OAuth2 tokens, those requests use the client set with WithHttpClient as is
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(client *client) {
+		client.useCookies = true
+		client.cookieOption = true
+		client.cookieJar = jar
+		client.cookieClient = nil
 	}
 }
 
+// WithParentClient makes the client a sub-client of parent, for example scoped to one resource of an API. A sub-client
+// without its own authentication, base URL or http client (set by WithHttpClient, WithTransport and the other transport
+// options) uses the parent's. The parent's cookie handling comes with its http client, unless WithUseCookies or
+// WithCookieJar is set on the sub-client. Other settings, like the media type, are not inherited
 func WithParentClient(parent Client) Option {
 	return func(client *client) {
 		client.parentClient = parent
@@ -120,6 +684,7 @@ func WithParentClient(parent Client) Option {
 
 // WithPreflightAuth sets the client to use preflight authentication. The given function will be called before each request
 // you can get the parent client by calling `GetParentClient()` on the given client, if registered with the WithParentClient option
+// The request body is buffered before the call, so the function may read req.Body (and req.GetBody) without consuming it
 func WithPreflightAuth(authFunc func(req *http.Request, client Client) (*http.Request, error)) Option {
 	return func(client *client) {
 		client.authType = authTypePreflight
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+)
+
+// PageRequest is a request for a single page of a paginated endpoint, NextPage builds the request for the following
+// page from the response of this one and returns false when there are no more pages
+type PageRequest[T any] interface {
+	Request
+	NextPage(resp *T) (PageRequest[T], bool)
+}
+
+// Paginate does the given request and passes every page to the handler, following NextPage until there are no more
+// pages or the handler returns an error
+func Paginate[T any](ctx context.Context, client Client, req PageRequest[T], handler func(T) error) error {
+	for {
+		var resp T
+		if err := client.Do(ctx, req, &resp); err != nil {
+			return err
+		}
+		if err := handler(resp); err != nil {
+			return err
+		}
+
+		next, ok := req.NextPage(&resp)
+		if !ok {
+			return nil
+		}
+		req = next
+	}
+}
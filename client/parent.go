@@ -0,0 +1,27 @@
+package client
+
+import (
+	"net/url"
+)
+
+// parent returns the parent client, or nil when there is none
+func (c *client) parent() *client {
+	parent, _ := c.parentClient.(*client)
+	return parent
+}
+
+// authClient returns the client whose authentication is used, the parent's when this client has none
+func (c *client) authClient() *client {
+	if parent := c.parent(); c.authType == authTypeNone && parent != nil {
+		return parent.authClient()
+	}
+	return c
+}
+
+// getBaseURL returns the base URL, the parent's when this client has none
+func (c *client) getBaseURL() *url.URL {
+	if parent := c.parent(); c.baseURL == nil && parent != nil {
+		return parent.getBaseURL()
+	}
+	return c.baseURL
+}
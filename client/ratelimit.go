@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"golang.org/x/time/rate"
+)
+
+type (
+	// RateLimiter is consulted before every attempt of a request, Wait blocks until the request is allowed to proceed
+	RateLimiter interface {
+		Wait(ctx context.Context) error
+	}
+
+	tokenBucketLimiter struct {
+		limiter *rate.Limiter
+	}
+)
+
+var _ RateLimiter = (*tokenBucketLimiter)(nil)
+
+// NewTokenBucketLimiter returns a RateLimiter allowing rps requests per second on average, with bursts of up to burst
+// requests
+func NewTokenBucketLimiter(rps float64, burst int) RateLimiter {
+	return &tokenBucketLimiter{
+		limiter: rate.NewLimiter(rate.Limit(rps), burst),
+	}
+}
+
+func (l *tokenBucketLimiter) Wait(ctx context.Context) error {
+	return l.limiter.Wait(ctx)
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaxRetryAfter is the longest Retry-After delay that is waited for unless WithMaxRetryAfter is set
+const defaultMaxRetryAfter = time.Minute
+
+var defaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+func (c *client) isRetryableStatus(statusCode int) bool {
+	if c.retryableStatus == nil {
+		return slices.Contains(defaultRetryableStatusCodes, statusCode)
+	}
+	return slices.Contains(c.retryableStatus, statusCode)
+}
+
+// retryResponse reports whether resp is retried, decided by the retry predicate when set and by the status code
+// otherwise. The predicate gets a body it can read, which is reset afterwards
+func (c *client) retryResponse(resp *http.Response) (bool, error) {
+	if c.retryPredicate == nil {
+		return c.isRetryableStatus(resp.StatusCode), nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	retry := c.retryPredicate(resp, nil)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return retry, nil
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a number of seconds or an HTTP date.
+// The second return value is false when the header is absent or cannot be parsed
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		if seconds > int(math.MaxInt64/time.Second) {
+			return math.MaxInt64, true
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		d := t.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
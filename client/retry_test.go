@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "seconds", value: "120", want: 2 * time.Minute, wantOK: true},
+		{name: "http date", value: now.Add(time.Hour).Format(http.TimeFormat), want: time.Hour, wantOK: true},
+		{name: "date in the past", value: now.Add(-time.Hour).Format(http.TimeFormat), want: 0, wantOK: true},
+		{name: "overflowing seconds", value: "99999999999", want: math.MaxInt64, wantOK: true},
+		{name: "negative", value: "-1"},
+		{name: "empty"},
+		{name: "invalid", value: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Fatalf("expected %s, %t, got %s, %t", tt.want, tt.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestRetryAfterAboveTheLimitIsNotRetried(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		opts       []Option
+		wantHits   int
+	}{
+		{name: "within the limit", retryAfter: "0", wantHits: 2},
+		{name: "above the default limit", retryAfter: "99999999999", wantHits: 1},
+		{name: "above a configured limit", retryAfter: "2", opts: []Option{WithMaxRetryAfter(time.Second)}, wantHits: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := 0
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				hits++
+				w.Header().Set("Retry-After", tt.retryAfter)
+				writeJSON(w, http.StatusServiceUnavailable, `{}`)
+			}, append([]Option{WithMaxRetries(1)}, tt.opts...)...)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := c.Do(ctx, testRequest{method: http.MethodGet, path: "/"}, nil)
+			var errResponse ErrorResponse
+			if !errors.As(err, &errResponse) {
+				t.Fatalf("expected an ErrorResponse, got %v", err)
+			}
+			if hits != tt.wantHits {
+				t.Fatalf("expected %d requests, got %d", tt.wantHits, hits)
+			}
+		})
+	}
+}
+
+func TestRetryPredicateDecidesOnPerAttemptTimeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		retry    bool
+		wantHits int
+	}{
+		{name: "rejected", retry: false, wantHits: 1},
+		{name: "accepted", retry: true, wantHits: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			calls := 0
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if hits.Add(1) == 1 {
+					<-r.Context().Done()
+					return
+				}
+				writeJSON(w, http.StatusOK, `{}`)
+			}, WithPerAttemptTimeout(50*time.Millisecond), WithMaxRetries(1), withoutBackoff(),
+				WithRetryPredicate(func(resp *http.Response, err error) bool {
+					calls++
+					return tt.retry
+				}))
+
+			err := c.Do(context.Background(), testRequest{method: http.MethodGet, path: "/"}, nil)
+			if tt.retry && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if !tt.retry && !errors.Is(err, ErrTimeout) {
+				t.Fatalf("expected ErrTimeout, got %v", err)
+			}
+			if int(hits.Load()) != tt.wantHits || calls != 1 {
+				t.Fatalf("expected %d requests and 1 predicate call, got %d and %d", tt.wantHits, hits.Load(), calls)
+			}
+		})
+	}
+}
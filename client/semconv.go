@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// stableSemconvVersion is the first semantic conventions version with the stable http attribute names
+const stableSemconvVersion = "1.23.0"
+
+// semconvKeys holds the span attribute names used for a semantic conventions version
+type semconvKeys struct {
+	method        attribute.Key
+	url           attribute.Key
+	statusCode    attribute.Key
+	contentLength attribute.Key
+	resendCount   attribute.Key
+	stable        bool
+}
+
+var (
+	legacySemconv = semconvKeys{
+		method:        "http.method",
+		url:           "http.url",
+		statusCode:    "http.status_code",
+		contentLength: "http.response_content_length",
+		resendCount:   "http.resend_count",
+	}
+	stableSemconv = semconvKeys{
+		method:        "http.request.method",
+		url:           "url.full",
+		statusCode:    "http.response.status_code",
+		contentLength: "http.response.body.size",
+		resendCount:   "http.request.resend_count",
+		stable:        true,
+	}
+)
+
+// parseSemconvVersion parses a version like v1.26.0 or 1.26 into its major and minor number
+func parseSemconvVersion(version string) (major, minor int, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("invalid semantic conventions version %q", version)
+	}
+	if major, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, fmt.Errorf("invalid semantic conventions version %q: %w", version, err)
+	}
+	if minor, err = strconv.Atoi(parts[1]); err != nil {
+		return 0, 0, fmt.Errorf("invalid semantic conventions version %q: %w", version, err)
+	}
+	return major, minor, nil
+}
+
+func (c *client) semconv() semconvKeys {
+	if c.semconvKeys == nil {
+		return legacySemconv
+	}
+	return *c.semconvKeys
+}
+
+// requestAttributes returns the span attributes describing req
+func (c *client) requestAttributes(req *http.Request) []attribute.KeyValue {
+	keys := c.semconv()
+	attrs := []attribute.KeyValue{
+		keys.method.String(req.Method),
+		keys.url.String(req.URL.String()),
+	}
+	if keys.stable {
+		attrs = append(attrs, attribute.String("server.address", req.URL.Hostname()))
+		if port := req.URL.Port(); port != "" {
+			if p, err := strconv.Atoi(port); err == nil {
+				attrs = append(attrs, attribute.Int("server.port", p))
+			}
+		}
+	}
+	return attrs
+}
+
+// setErrorType sets the error.type attribute for a failed request when the stable conventions are used
+func (c *client) setErrorType(span trace.Span, err error) {
+	if err == nil || !c.semconv().stable || errors.Is(err, ErrNotModified) {
+		return
+	}
+	span.SetAttributes(attribute.String("error.type", errorType(err)))
+}
+
+// errorType describes the failure of a request for the error.type attribute: the status code for error responses,
+// otherwise the kind of error
+func errorType(err error) string {
+	var errResponse ErrorResponse
+	switch {
+	case errors.As(err, &errResponse) && errResponse.response != nil && errResponse.response.StatusCode >= 400:
+		return strconv.Itoa(errResponse.response.StatusCode)
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, ErrAuthFailure):
+		return "auth_failure"
+	case errors.Is(err, ErrResponseTooLarge):
+		return "response_too_large"
+	}
+	return "_OTHER"
+}
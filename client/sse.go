@@ -0,0 +1,170 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sseMediaType        = "text/event-stream"
+	defaultSSERetryTime = 3 * time.Second
+)
+
+type (
+	// SSEEvent is a single server-sent event, Retry is only set when the event carried a retry field
+	SSEEvent struct {
+		ID    string
+		Event string
+		Data  string
+		Retry time.Duration
+	}
+
+	// sseStream is passed as response by Subscribe, it keeps the state needed to reconnect
+	sseStream struct {
+		ctx         context.Context
+		events      chan<- SSEEvent
+		lastEventID string
+		retry       time.Duration
+		done        bool
+	}
+)
+
+// Subscribe sends the request as a server-sent events subscription and emits the received events on the channel. When
+// the stream ends or the connection fails it reconnects after the retry interval suggested by the server, sending the
+// last event ID. It returns when the context is done, the server responds with an error or 204 No Content, and on
+// any other error that reconnecting won't resolve, like a missing base URL or failing authentication
+func (c *client) Subscribe(ctx context.Context, request Request, events chan<- SSEEvent) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	stream := &sseStream{
+		ctx:    ctx,
+		events: events,
+		retry:  defaultSSERetryTime,
+	}
+
+	for {
+		err := c.Do(ctx, request, stream)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if stream.done {
+			return nil
+		}
+
+		if !sseReconnect(err) {
+			return err
+		}
+
+		if err := c.getClock().Sleep(ctx, stream.retry); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *sseStream) prepare(req *http.Request) {
+	req.Header.Set("Accept", sseMediaType)
+	req.Header.Set("Cache-Control", "no-cache")
+	if s.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", s.lastEventID)
+	}
+}
+
+var (
+	errSSEContentType  = errors.New("response is not an event stream")
+	errSSEDisconnected = errors.New("event stream disconnected")
+)
+
+// sseReconnect reports whether Subscribe reconnects after the error of a subscription, only when the stream ended or
+// the connection failed. Other errors come back the same on every attempt
+func sseReconnect(err error) bool {
+	if err == nil {
+		return true
+	}
+	var errResponse ErrorResponse
+	if errors.As(err, &errResponse) || errors.Is(err, ErrAuthFailure) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, errSSEDisconnected)
+}
+
+func (s *sseStream) handle(resp *http.Response) error {
+	if resp.StatusCode == http.StatusNoContent {
+		s.done = true
+		return nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != sseMediaType {
+		return fmt.Errorf("%w: content type %s", errSSEContentType, resp.Header.Get("Content-Type"))
+	}
+	if err := s.read(resp.Body); err != nil {
+		return fmt.Errorf("%w: %w", errSSEDisconnected, err)
+	}
+	return nil
+}
+
+// read parses the event stream as described in the html standard, emitting every complete event
+func (s *sseStream) read(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	var event SSEEvent
+	var data []string
+
+	for {
+		line, err := reader.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			// an incomplete event at the end of the stream is discarded
+			return nil
+		} else if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if len(data) > 0 {
+				event.ID = s.lastEventID
+				event.Data = strings.Join(data, "\n")
+				if event.Event == "" {
+					event.Event = "message"
+				}
+				select {
+				case s.events <- event:
+				case <-s.ctx.Done():
+					return s.ctx.Err()
+				}
+			}
+			event = SSEEvent{}
+			data = nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				event.Retry = time.Duration(ms) * time.Millisecond
+				s.retry = event.Retry
+			}
+		}
+	}
+}
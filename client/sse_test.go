@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSubscribeReturnsErrorsReconnectingWontResolve(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := NewClient().Subscribe(ctx, testRequest{method: http.MethodGet, path: "/events"}, make(chan SSEEvent))
+	if err == nil || err.Error() != "client base URL not set" {
+		t.Fatalf("expected the missing base URL error, got %v", err)
+	}
+}
+
+func TestSubscribeReconnectsWithTheLastEventID(t *testing.T) {
+	var lastEventIDs []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		if len(lastEventIDs) > 1 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("id: 1\ndata: hello\n\n"))
+	}, WithClock(&testClock{}))
+
+	events := make(chan SSEEvent, 1)
+	if err := c.Subscribe(context.Background(), testRequest{method: http.MethodGet, path: "/events"}, events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event := <-events; event.ID != "1" || event.Data != "hello" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if len(lastEventIDs) != 2 || lastEventIDs[1] != "1" {
+		t.Fatalf("expected a reconnect with Last-Event-ID 1, got %q", lastEventIDs)
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// bodyTee holds the request and response body of the last attempt of a request, for the sink set by WithBodyTee
+type bodyTee struct {
+	request  []byte
+	response []byte
+}
+
+func bodyTeeFromContext(ctx context.Context) *bodyTee {
+	tee, _ := ctx.Value(contextKeyBodyTee).(*bodyTee)
+	return tee
+}
+
+// teeRequestBody copies the request body, streamed bodies are buffered so they can still be sent
+func (t *bodyTee) teeRequestBody(req *http.Request) error {
+	t.request, t.response = nil, nil
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.GetBody == nil {
+		body, err := readRequestBody(req)
+		t.request = body
+		return err
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Errorf("failed to copy request body: %w", err)
+	}
+	defer body.Close()
+	if t.request, err = io.ReadAll(body); err != nil {
+		return fmt.Errorf("failed to copy request body: %w", err)
+	}
+	return nil
+}
+
+// teeResponseBody copies the buffered response body and resets it
+func (t *bodyTee) teeResponseBody(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	t.response = body
+	return nil
+}
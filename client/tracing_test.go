@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTraceContextIsPropagated(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var traceparent string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		writeJSON(w, http.StatusOK, `{}`)
+	}, WithTracerProvider(provider), WithPropagator(propagation.TraceContext{}))
+
+	if err := c.Do(context.Background(), testRequest{method: http.MethodGet, path: "/"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	sc := spans[0].SpanContext()
+	want := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-"
+	if !strings.HasPrefix(traceparent, want) {
+		t.Fatalf("expected traceparent starting with %q, got %q", want, traceparent)
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// newDefaultHttpClient returns a client owned by a single Client, so options never mutate http.DefaultClient
+func newDefaultHttpClient() *http.Client {
+	return &http.Client{
+		Transport: newDefaultTransport(),
+	}
+}
+
+func newDefaultTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// modifyTransport changes a copy of the transport of the http client, so neither a client set with WithHttpClient nor
+// http.DefaultTransport is modified. Round trippers that aren't an *http.Transport can't be modified, which is
+// returned as error by Do
+func (c *client) modifyTransport(option string, modify func(transport *http.Transport)) {
+	var transport *http.Transport
+	switch rt := c.httpClient.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = rt.Clone()
+	default:
+		c.optionErrs = append(c.optionErrs, fmt.Errorf("%s: transport %T is not an *http.Transport", option, rt))
+		return
+	}
+	modify(transport)
+
+	c.modifyHttpClient(func(httpClient *http.Client) {
+		httpClient.Transport = transport
+	})
+}
+
+// modifyHttpClient changes a copy of the http client, so a client set with WithHttpClient isn't modified. The copy is
+// used for token requests too
+func (c *client) modifyHttpClient(modify func(httpClient *http.Client)) {
+	httpClient := *c.httpClient
+	modify(&httpClient)
+	c.ownHttpClient = true
+	c.httpClient = &httpClient
+	c.baseClient = &httpClient
+}
+
+// modifyTLSConfig changes the TLS config of a copy of the transport, see modifyTransport
+func (c *client) modifyTLSConfig(option string, modify func(config *tls.Config)) {
+	c.modifyTransport(option, func(transport *http.Transport) {
+		// Clone of the transport already cloned its TLS config
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		modify(transport.TLSClientConfig)
+	})
+}
+
+// warnInsecure warns once per client that certificates aren't verified, without a logger it is written to the
+// standard logger even when debug is disabled
+func (c *client) warnInsecure(ctx context.Context) {
+	c.insecureWarning.Do(func() {
+		const msg = "TLS certificate verification is disabled, do not use this in production"
+		if c.logger != nil {
+			c.logger.LogAttrs(ctx, slog.LevelWarn, msg)
+			return
+		}
+		log.Println(msg)
+	})
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newClientCertificate returns a self-signed certificate for client authentication
+func newClientCertificate(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, cert
+}
+
+func TestClientCertificate(t *testing.T) {
+	clientCert, leaf := newClientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(leaf)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, `{}`)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(srv.Certificate())
+	req := testRequest{method: http.MethodGet, path: "/"}
+
+	c := NewClient(WithBaseURLString(srv.URL), WithRootCAs(rootCAs), WithClientCertificate(clientCert))
+	if err := c.Do(context.Background(), req, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	withoutCert := NewClient(WithBaseURLString(srv.URL), WithRootCAs(rootCAs))
+	if err := withoutCert.Do(context.Background(), req, nil); err == nil {
+		t.Fatal("expected the request without client certificate to fail")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var via string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		via = r.Header.Get("Via")
+		writeJSON(w, http.StatusOK, `{}`)
+	}))
+	t.Cleanup(target.Close)
+
+	var proxyAuth string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxyAuth = r.Header.Get("Proxy-Authorization")
+		out := r.Clone(r.Context())
+		out.RequestURI = ""
+		out.Header.Del("Proxy-Authorization")
+		out.Header.Set("Via", "1.1 test-proxy")
+		resp, err := http.DefaultTransport.RoundTrip(out)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		for k, vv := range resp.Header {
+			w.Header()[k] = vv
+		}
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(proxy.Close)
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	proxyURL.User = url.UserPassword("user", "pass")
+	c := NewClient(WithBaseURLString(target.URL), WithProxy(proxyURL))
+
+	if err := c.Do(context.Background(), testRequest{method: http.MethodGet, path: "/"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if via != "1.1 test-proxy" {
+		t.Fatalf("expected the request to pass the proxy, got Via %q", via)
+	}
+	if want := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass")); proxyAuth != want {
+		t.Fatalf("expected Proxy-Authorization %q, got %q", want, proxyAuth)
+	}
+}
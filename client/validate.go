@@ -0,0 +1,64 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NewClientWithError is NewClient, but returns an error when the configuration is invalid instead of failing on the
+// first call to Do
+func NewClientWithError(opts ...Option) (Client, error) {
+	c := NewClient(opts...).(*client)
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid client configuration: %w", err)
+	}
+	return c, nil
+}
+
+func (c *client) validate() error {
+	errs := append([]error{}, c.optionErrs...)
+
+	if baseURL := c.getBaseURL(); baseURL == nil {
+		errs = append(errs, errors.New("base URL not set"))
+	} else if !baseURL.IsAbs() || baseURL.Host == "" {
+		errs = append(errs, fmt.Errorf("base URL %q is not absolute", baseURL.String()))
+	}
+
+	switch c.authType {
+	case authTypeBasic:
+		if c.userName == "" {
+			errs = append(errs, errors.New("basic auth username not set"))
+		}
+	case authTypeApiKey:
+		if c.keyHeader == "" || c.keyValue == "" {
+			errs = append(errs, errors.New("api key header or value not set"))
+		}
+	case authTypeOAuth2:
+		if c.oauth2Config == nil && c.tokenSource == nil {
+			errs = append(errs, errors.New("oauth2 config or token source not set"))
+		}
+	case authTypeBearer:
+		if c.bearerToken == "" {
+			errs = append(errs, errors.New("bearer token not set"))
+		}
+	case authTypeHMAC:
+		if len(c.hmacConfig.Secret) == 0 {
+			errs = append(errs, errors.New("hmac secret not set"))
+		}
+	case authTypeDigest:
+		if c.digestAuth == nil || c.digestAuth.username == "" {
+			errs = append(errs, errors.New("digest auth username not set"))
+		}
+	case authTypeAWSSigV4:
+		if c.sigV4 == nil || c.sigV4.credentials.AccessKeyID == "" || c.sigV4.credentials.SecretAccessKey == "" {
+			errs = append(errs, errors.New("aws credentials not set"))
+		}
+	case authTypePreflight:
+		if c.preflightAuthFunc == nil {
+			errs = append(errs, errors.New("preflight auth function not set"))
+		}
+	default:
+	}
+
+	return errors.Join(errs...)
+}